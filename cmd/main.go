@@ -2,10 +2,12 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"DLM_backend/config"
 	"DLM_backend/database"
 	"DLM_backend/routers"
+	"DLM_backend/services"
 	"DLM_backend/utils"
 )
 
@@ -18,11 +20,71 @@ func main() {
 
 	// 设置 JWT 密钥
 	utils.SetJWTSecret(cfg.JWTSecret)
+	utils.SetAccessTokenTTL(cfg.AccessTokenTTL)
+	services.SetRefreshTokenTTL(cfg.RefreshTokenTTL)
+
+	// 设置保管员修改记录的时间窗口
+	services.SetRecordEditWindow(cfg.RecordEditWindow)
+
+	// 设置回收站记录的保留时间
+	services.SetRecordRetention(cfg.RecordRetention)
+
+	// 设置整改工单的默认整改期限
+	services.SetRectificationDeadline(cfg.RectificationDeadline)
+
+	// 设置挡粮门二维码签名密钥，未单独配置时使用 JWT 密钥
+	qrSecret := cfg.QRSecret
+	if qrSecret == "" {
+		qrSecret = cfg.JWTSecret
+	}
+	services.SetQRConfig(qrSecret, cfg.QRLabelFont)
+
+	// 设置微信小程序登录配置
+	services.SetWechatConfig(cfg.WechatAppID, cfg.WechatAppSecret, cfg.WechatCode2SessionURL)
+
+	// 设置上传图片限制和缩略版本尺寸
+	services.SetImagePolicy(services.ImagePolicy{
+		MaxBytes:  cfg.ImageMaxBytes,
+		MaxWidth:  cfg.ImageMaxWidth,
+		MaxHeight: cfg.ImageMaxHeight,
+		MaxPixels: cfg.ImageMaxPixels,
+
+		ThumbSize:  cfg.ImageThumbSize,
+		MediumSize: cfg.ImageMediumSize,
+	})
+
+	// 设置登录失败锁定策略
+	services.SetLoginGuardConfig(services.LoginGuardConfig{
+		MaxFailures:   cfg.LoginMaxFailures,
+		IPMaxFailures: cfg.LoginIPMaxFailures,
+		FailureWindow: cfg.LoginFailureWindow,
+		LockBase:      cfg.LoginLockBase,
+		LockMax:       cfg.LoginLockMax,
+	})
+
+	// 设置密码强度策略
+	utils.SetPasswordPolicy(utils.PasswordPolicy{
+		MinLength:      cfg.PasswordMinLength,
+		RequireLetter:  cfg.PasswordRequireLetter,
+		RequireDigit:   cfg.PasswordRequireDigit,
+		RequireSpecial: cfg.PasswordRequireSpecial,
+	})
 
 	// 初始化数据库连接
 	db := database.InitDB(cfg)
 	_ = db // 后续可使用 db 进行数据库操作
 
+	// 定期清理过期的令牌记录
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := services.PurgeExpiredTokens(); err != nil {
+				log.Printf("failed to purge expired tokens: %v", err)
+			}
+		}
+	}()
+
 	// 初始化路由
 	r := routers.SetupRouter()
 
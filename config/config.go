@@ -2,10 +2,14 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 )
 
+// ImageUploadDir 上传图片及其缩略版本的存储目录，相对于服务运行目录
+const ImageUploadDir = "uploads/images"
+
 // Config 存储数据库和 JWT 的配置信息
 type Config struct {
 	DBDriver   string `env:"DB_DRIVER" envDefault:"sqlite3"` // 数据库驱动，可选 "mysql" 或 "sqlite3"
@@ -16,6 +20,49 @@ type Config struct {
 	DBName     string `env:"DB_NAME" envDefault:"dlm"`
 	SQLitePath string `env:"SQLITE_PATH" envDefault:"sqlite.db"` // sqlite数据库文件路径
 	JWTSecret  string `env:"JWT_SECRET" envDefault:"secret"`
+
+	AccessTokenTTL  time.Duration `env:"ACCESS_TOKEN_TTL" envDefault:"30m"`   // 访问令牌有效期
+	RefreshTokenTTL time.Duration `env:"REFRESH_TOKEN_TTL" envDefault:"720h"` // 刷新令牌有效期
+
+	// 保管员提交记录后可修改/删除的时间窗口，0 表示不限制
+	RecordEditWindow time.Duration `env:"RECORD_EDIT_WINDOW" envDefault:"24h"`
+
+	// 挡粮门二维码签名密钥，为空时使用 JWT 密钥
+	QRSecret string `env:"QR_SECRET" envDefault:""`
+	// 打印二维码标签使用的 TrueType 字体文件，用于显示中文，为空时标签只显示编号
+	QRLabelFont string `env:"QR_LABEL_FONT" envDefault:""`
+
+	// 删除的点检记录在回收站中的保留时间，超过后管理员才能彻底删除
+	RecordRetention time.Duration `env:"RECORD_RETENTION" envDefault:"720h"`
+
+	// 点检发现异常后自动生成的整改工单的默认整改期限
+	RectificationDeadline time.Duration `env:"RECTIFICATION_DEADLINE" envDefault:"72h"`
+
+	// 上传图片限制，只接受 JPEG、PNG 和 WebP
+	ImageMaxBytes   int64 `env:"IMAGE_MAX_BYTES" envDefault:"10485760"`  // 文件最大字节数
+	ImageMaxWidth   int   `env:"IMAGE_MAX_WIDTH" envDefault:"8192"`      // 最大宽度（像素）
+	ImageMaxHeight  int   `env:"IMAGE_MAX_HEIGHT" envDefault:"8192"`     // 最大高度（像素）
+	ImageMaxPixels  int   `env:"IMAGE_MAX_PIXELS" envDefault:"40000000"` // 最大像素数（宽×高）
+	ImageThumbSize  int   `env:"IMAGE_THUMB_SIZE" envDefault:"320"`      // 缩略图长边像素，0 表示不生成
+	ImageMediumSize int   `env:"IMAGE_MEDIUM_SIZE" envDefault:"1280"`    // 中图长边像素，0 表示不生成
+
+	// 登录防暴力破解配置
+	LoginMaxFailures   int           `env:"LOGIN_MAX_FAILURES" envDefault:"5"`     // 同一用户名连续失败多少次后锁定
+	LoginIPMaxFailures int           `env:"LOGIN_IP_MAX_FAILURES" envDefault:"20"` // 同一IP连续失败多少次后锁定
+	LoginFailureWindow time.Duration `env:"LOGIN_FAILURE_WINDOW" envDefault:"15m"` // 超过该时间未再失败则重新计数
+	LoginLockBase      time.Duration `env:"LOGIN_LOCK_BASE" envDefault:"1m"`       // 首次锁定时长，之后每次翻倍
+	LoginLockMax       time.Duration `env:"LOGIN_LOCK_MAX" envDefault:"24h"`       // 最长锁定时长
+
+	// 微信小程序登录配置，Code2SessionURL 可指向本地桩服务用于测试
+	WechatAppID           string `env:"WECHAT_APPID" envDefault:""`
+	WechatAppSecret       string `env:"WECHAT_SECRET" envDefault:""`
+	WechatCode2SessionURL string `env:"WECHAT_CODE2SESSION_URL" envDefault:"https://api.weixin.qq.com/sns/jscode2session"`
+
+	// 密码强度策略
+	PasswordMinLength      int  `env:"PASSWORD_MIN_LENGTH" envDefault:"8"`          // 最小长度
+	PasswordRequireLetter  bool `env:"PASSWORD_REQUIRE_LETTER" envDefault:"true"`   // 必须包含字母
+	PasswordRequireDigit   bool `env:"PASSWORD_REQUIRE_DIGIT" envDefault:"true"`    // 必须包含数字
+	PasswordRequireSpecial bool `env:"PASSWORD_REQUIRE_SPECIAL" envDefault:"false"` // 必须包含特殊字符
 }
 
 // DSN 返回数据库连接字符串，根据驱动不同返回不同的DSN
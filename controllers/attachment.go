@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"strings"
+
+	"DLM_backend/models"
+	"DLM_backend/services"
+	"DLM_backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListRecordAttachments 获取点检记录的图片附件，提供 field 参数时只返回该点检项的图片（field 为空字符串表示整条记录的图片）
+func ListRecordAttachments(c *gin.Context) {
+	record, ok := loadScopedRecord(c)
+	if !ok {
+		return
+	}
+
+	var field *string
+	if value, exists := c.GetQuery("field"); exists {
+		field = &value
+	}
+	attachments, err := services.GetRecordAttachments(record.ID, field)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get attachments")
+		return
+	}
+	utils.SuccessResponse(c, attachments)
+}
+
+// UploadRecordAttachment 上传图片并追加到点检记录，field 为点检项标识，为空时作为整条记录的图片
+// 可同时提供 caption（图片说明）和 captured_at（拍摄时间，RFC3339），修改权限与修改点检记录相同
+func UploadRecordAttachment(c *gin.Context) {
+	record, ok := loadScopedRecord(c)
+	if !ok {
+		return
+	}
+	userID := currentUserID(c)
+	canUpdateAny := utils.HasPermission(c, models.PermInspectionUpdateAny)
+	if !canUpdateAny && !utils.HasPermission(c, models.PermInspectionUpdateOwn) {
+		utils.ForbiddenResponse(c, "permission denied")
+		return
+	}
+	if err := services.CheckRecordModifiable(record, userID, canUpdateAny); err != nil {
+		respondRecordError(c, err, "failed to update record")
+		return
+	}
+
+	limitUploadBody(c)
+	file, err := formImageFile(c, "file")
+	if err != nil {
+		respondFormFileError(c, err)
+		return
+	}
+	attachment, err := newAttachmentFromForm(c)
+	if err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+	attachment.Field = strings.TrimSpace(c.PostForm("field"))
+	if err := services.CheckAttachmentField(record, attachment.Field); err != nil {
+		respondRecordError(c, err, "failed to add attachment")
+		return
+	}
+	if err := saveUploadedImage(c, file, attachment); err != nil {
+		respondImageError(c, err)
+		return
+	}
+
+	updated, err := services.AddRecordAttachment(record, attachment, userID)
+	if err != nil {
+		// 记录未保存，已保存的图片不会被引用
+		removeUploadedImages([]*models.Attachment{attachment})
+		respondRecordError(c, err, "failed to add attachment")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{
+		"attachment": attachment,
+		"record":     updated,
+	})
+}
@@ -1,8 +1,11 @@
 package controllers
 
 import (
+	"errors"
+	"strconv"
+	"time"
+
 	"DLM_backend/database"
-	"DLM_backend/models"
 	"DLM_backend/services"
 	"DLM_backend/utils"
 
@@ -14,7 +17,7 @@ import (
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
-	Role     string `json:"role" binding:"required"` // 角色: "keeper"(保管员) 或 "admin"(管理员)
+	Role     string `json:"role" binding:"required"` // 角色名称，例如 "keeper"(保管员) 或 "admin"(管理员)
 }
 
 // UserProfileRequest 用户个人信息修改请求结构体
@@ -23,6 +26,46 @@ type UserProfileRequest struct {
 	Phone string `json:"phone"`
 }
 
+// ChangePasswordRequest 修改密码请求结构体
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// RefreshTokenRequest 刷新令牌请求结构体
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest 退出登录请求结构体
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"` // 可选，提供时一并吊销该刷新令牌所在的令牌族
+}
+
+// clientInfo 提取请求的客户端信息，用于记录令牌签发来源
+func clientInfo(c *gin.Context) services.ClientInfo {
+	return services.ClientInfo{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+}
+
+// currentUserID 返回当前登录用户的ID，未登录时返回 0
+func currentUserID(c *gin.Context) int {
+	if user := utils.CurrentUser(c); user != nil {
+		return user.ID
+	}
+	return 0
+}
+
+// respondLoginLocked 登录被临时锁定时返回 429 并设置 Retry-After
+func respondLoginLocked(c *gin.Context, err error) bool {
+	var lockedErr *services.LoginLockedError
+	if !errors.As(err, &lockedErr) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(lockedErr.RetryAfter()))
+	utils.TooManyRequestsResponse(c, lockedErr.Error())
+	return true
+}
+
 // Login 用于处理用户登录请求
 func Login(c *gin.Context) {
 	var loginData LoginRequest
@@ -32,20 +75,29 @@ func Login(c *gin.Context) {
 	}
 
 	// 验证角色是否有效
-	if loginData.Role != "keeper" && loginData.Role != "admin" {
-		utils.ErrorResponse(c, "invalid role, must be 'keeper' or 'admin'")
+	if !services.RoleExists(loginData.Role) {
+		utils.ErrorResponse(c, "invalid role")
 		return
 	}
 
 	// 根据用户名、密码和角色进行认证
-	token, err := services.AuthenticateUser(loginData.Username, loginData.Password, loginData.Role)
+	tokens, err := services.AuthenticateUser(loginData.Username, loginData.Password, loginData.Role, clientInfo(c))
+	if respondLoginLocked(c, err) {
+		return
+	}
+	if errors.Is(err, services.ErrAccountDisabled) {
+		utils.UnauthorizedResponse(c, "account disabled")
+		return
+	}
 	if err != nil {
 		utils.ErrorResponse(c, "invalid credentials")
 		return
 	}
 
 	utils.SuccessResponse(c, gin.H{
-		"token": token,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 		"user": gin.H{
 			"username": loginData.Username,
 			"role":     loginData.Role,
@@ -55,43 +107,33 @@ func Login(c *gin.Context) {
 
 // GetUserProfile 获取用户个人信息
 func GetUserProfile(c *gin.Context) {
-	// 从JWT中获取用户名
-	claims, exists := c.Get("claims")
-	if !exists {
+	// 获取JWT中间件加载的当前用户
+	user := utils.CurrentUser(c)
+	if user == nil {
 		utils.UnauthorizedResponse(c, "token claims not found")
 		return
 	}
 
-	username := claims.(jwt.MapClaims)["username"].(string)
-
-	// 从数据库获取用户信息
-	var user models.User
-	if err := database.DB.Where("username = ?", username).First(&user).Error; err != nil {
-		utils.NotFoundResponse(c, "user not found")
-		return
-	}
-
 	// 返回用户信息，排除敏感信息如密码
 	utils.SuccessResponse(c, gin.H{
-		"id":       user.ID,
-		"username": user.Username,
-		"role":     user.Role,
-		"name":     user.Name,
-		"phone":    user.Phone,
+		"id":           user.ID,
+		"username":     user.Username,
+		"role":         user.Role,
+		"name":         user.Name,
+		"phone":        user.Phone,
+		"wechat_bound": user.OpenID != nil,
 	})
 }
 
 // UpdateUserProfile 更新用户个人信息
 func UpdateUserProfile(c *gin.Context) {
-	// 从JWT中获取用户名
-	claims, exists := c.Get("claims")
-	if !exists {
+	// 获取JWT中间件加载的当前用户
+	user := utils.CurrentUser(c)
+	if user == nil {
 		utils.UnauthorizedResponse(c, "token claims not found")
 		return
 	}
 
-	username := claims.(jwt.MapClaims)["username"].(string)
-
 	// 解析请求数据
 	var profileData UserProfileRequest
 	if err := c.ShouldBindJSON(&profileData); err != nil {
@@ -99,13 +141,6 @@ func UpdateUserProfile(c *gin.Context) {
 		return
 	}
 
-	// 更新用户信息
-	var user models.User
-	if err := database.DB.Where("username = ?", username).First(&user).Error; err != nil {
-		utils.NotFoundResponse(c, "user not found")
-		return
-	}
-
 	// 只更新提供的字段
 	if profileData.Name != "" {
 		user.Name = profileData.Name
@@ -114,17 +149,96 @@ func UpdateUserProfile(c *gin.Context) {
 		user.Phone = profileData.Phone
 	}
 
-	if err := database.DB.Save(&user).Error; err != nil {
+	user.SetUpdater(user.ID)
+	if err := database.DB.Save(user).Error; err != nil {
 		utils.ServerErrorResponse(c, "failed to update user profile")
 		return
 	}
 
 	// 返回更新后的用户信息
 	utils.SuccessResponse(c, gin.H{
-		"id":       user.ID,
-		"username": user.Username,
-		"role":     user.Role,
-		"name":     user.Name,
-		"phone":    user.Phone,
+		"id":           user.ID,
+		"username":     user.Username,
+		"role":         user.Role,
+		"name":         user.Name,
+		"phone":        user.Phone,
+		"wechat_bound": user.OpenID != nil,
 	})
 }
+
+// ChangePassword 修改当前登录用户的密码
+// 修改成功后该用户此前签发的令牌全部失效，并返回新的令牌供当前设备继续使用
+func ChangePassword(c *gin.Context) {
+	user := utils.CurrentUser(c)
+	if user == nil {
+		utils.UnauthorizedResponse(c, "token claims not found")
+		return
+	}
+
+	var requestData ChangePasswordRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	tokens, err := services.ChangePassword(user, requestData.OldPassword, requestData.NewPassword, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, services.ErrWrongPassword) || errors.Is(err, utils.ErrWeakPassword) {
+			utils.ErrorResponse(c, err.Error())
+			return
+		}
+		utils.ServerErrorResponse(c, "failed to change password")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"message":       "password changed",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+	})
+}
+
+// RefreshToken 使用刷新令牌换取新的访问令牌，刷新令牌同时轮换
+func RefreshToken(c *gin.Context) {
+	var requestData RefreshTokenRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	tokens, err := services.RefreshTokens(requestData.RefreshToken, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			utils.UnauthorizedResponse(c, err.Error())
+			return
+		}
+		utils.ServerErrorResponse(c, "failed to refresh token")
+		return
+	}
+	utils.SuccessResponse(c, tokens)
+}
+
+// Logout 退出登录，吊销当前访问令牌及对应的刷新令牌
+func Logout(c *gin.Context) {
+	user := utils.CurrentUser(c)
+	claims, exists := c.Get("claims")
+	if user == nil || !exists {
+		utils.UnauthorizedResponse(c, "token claims not found")
+		return
+	}
+
+	// 请求体可以为空，此时只吊销访问令牌
+	var requestData LogoutRequest
+	_ = c.ShouldBindJSON(&requestData)
+
+	mapClaims := claims.(jwt.MapClaims)
+	jti, _ := mapClaims["jti"].(string)
+	exp, _ := mapClaims["exp"].(float64)
+
+	if err := services.Logout(user, jti, time.Unix(int64(exp), 0), requestData.RefreshToken); err != nil {
+		utils.ServerErrorResponse(c, "failed to logout")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"message": "logged out"})
+}
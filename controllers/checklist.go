@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+
+	"DLM_backend/models"
+	"DLM_backend/services"
+	"DLM_backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChecklistTemplateRequest 发布点检模板新版本请求结构体
+type ChecklistTemplateRequest struct {
+	Code     string                 `json:"code" binding:"required"`  // 模板编码，已有编码时发布该模板的新版本
+	Name     string                 `json:"name" binding:"required"`  // 模板名称
+	Items    []models.ChecklistItem `json:"items" binding:"required"` // 点检项
+	Remark   string                 `json:"remark"`                   // 版本说明
+	Activate bool                   `json:"activate"`                 // 是否立即用于新点检记录
+}
+
+// respondChecklistError 将点检模板服务层错误转换为响应
+func respondChecklistError(c *gin.Context, err error, fallback string) {
+	var fieldErr *services.FieldError
+	switch {
+	case errors.As(err, &fieldErr):
+		utils.FieldErrorResponse(c, fieldErr.Field, fieldErr.Message)
+	case errors.Is(err, services.ErrTemplateNotFound), errors.Is(err, services.ErrNoActiveTemplate):
+		utils.NotFoundResponse(c, err.Error())
+	default:
+		utils.ServerErrorResponse(c, fallback)
+	}
+}
+
+// GetActiveChecklistTemplate 获取新点检记录使用的模板，小程序据此渲染点检表单
+func GetActiveChecklistTemplate(c *gin.Context) {
+	template, err := services.GetActiveChecklistTemplate()
+	if err != nil {
+		respondChecklistError(c, err, "failed to get checklist template")
+		return
+	}
+	utils.SuccessResponse(c, template)
+}
+
+// GetChecklistTemplate 获取指定的模板版本，用于展示按旧版本填写的点检记录
+func GetChecklistTemplate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid checklist template id")
+		return
+	}
+	template, err := services.GetChecklistTemplateByID(id)
+	if err != nil {
+		respondChecklistError(c, err, "failed to get checklist template")
+		return
+	}
+	utils.SuccessResponse(c, template)
+}
+
+// ListChecklistTemplates 获取所有模板版本，可按 code 过滤
+func ListChecklistTemplates(c *gin.Context) {
+	templates, err := services.GetChecklistTemplates(c.Query("code"))
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get checklist templates")
+		return
+	}
+	utils.SuccessResponse(c, templates)
+}
+
+// CreateChecklistTemplate 发布点检模板的新版本，已发布的版本不能修改
+func CreateChecklistTemplate(c *gin.Context) {
+	var requestData ChecklistTemplateRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	template := models.ChecklistTemplate{
+		Code:   requestData.Code,
+		Name:   requestData.Name,
+		Items:  requestData.Items,
+		Remark: requestData.Remark,
+	}
+	created, err := services.CreateChecklistTemplate(&template, requestData.Activate, currentUserID(c))
+	if err != nil {
+		respondChecklistError(c, err, "failed to create checklist template")
+		return
+	}
+	utils.SuccessResponse(c, created)
+}
+
+// ActivateChecklistTemplate 将指定版本设为新点检记录使用的模板
+func ActivateChecklistTemplate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid checklist template id")
+		return
+	}
+	template, err := services.ActivateChecklistTemplate(id, currentUserID(c))
+	if err != nil {
+		respondChecklistError(c, err, "failed to activate checklist template")
+		return
+	}
+	utils.SuccessResponse(c, template)
+}
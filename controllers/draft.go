@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"DLM_backend/models"
+	"DLM_backend/services"
+	"DLM_backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/datatypes"
+)
+
+// maxDraftSize 草稿表单内容的最大字节数
+const maxDraftSize = 256 << 10
+
+// respondDraftError 将草稿服务层错误转换为响应，其余错误按点检记录错误处理
+func respondDraftError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrDraftNotFound):
+		utils.NotFoundResponse(c, err.Error())
+	case errors.Is(err, services.ErrDraftVersionConflict):
+		utils.ConflictResponse(c, err.Error())
+	default:
+		respondRecordError(c, err, fallback)
+	}
+}
+
+// bindDraft 读取草稿表单内容，不校验必填项，只校验各字段的类型与点检记录接口一致
+func bindDraft(c *gin.Context, draft *models.InspectionDraft) bool {
+	// 先限制请求体大小再读取，超过限制的草稿不会整体读入内存
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxDraftSize)
+	data, err := c.GetRawData()
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			utils.ErrorResponse(c, "draft is too large")
+			return false
+		}
+		utils.ErrorResponse(c, "failed to read draft")
+		return false
+	}
+	if len(data) == 0 {
+		data = []byte("{}")
+	}
+
+	var form map[string]json.RawMessage
+	if err := json.Unmarshal(data, &form); err != nil {
+		utils.ErrorResponse(c, "draft must be a JSON object")
+		return false
+	}
+	var requestData InspectionRequest
+	if err := json.Unmarshal(data, &requestData); err != nil {
+		utils.ErrorResponse(c, "invalid draft: "+err.Error())
+		return false
+	}
+
+	draft.Data = datatypes.JSON(data)
+	draft.Unit = requestData.Unit
+	draft.WarehouseNumber = requestData.WarehouseNumber
+	draft.GrainDoorPosition = requestData.GrainDoorPosition
+	return true
+}
+
+// ListInspectionDrafts 获取当前用户的草稿，可在任意设备上继续填写
+func ListInspectionDrafts(c *gin.Context) {
+	drafts, err := services.GetUserDrafts(currentUserID(c))
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get drafts")
+		return
+	}
+	utils.SuccessResponse(c, drafts)
+}
+
+// GetInspectionDraft 获取草稿内容
+func GetInspectionDraft(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid draft id")
+		return
+	}
+	draft, err := services.GetUserDraft(id, currentUserID(c))
+	if err != nil {
+		respondDraftError(c, err, "failed to get draft")
+		return
+	}
+	utils.SuccessResponse(c, draft)
+}
+
+// CreateInspectionDraft 新建草稿，请求体与新增点检记录相同，但所有字段都可以为空
+func CreateInspectionDraft(c *gin.Context) {
+	draft := models.InspectionDraft{UserID: currentUserID(c)}
+	if !bindDraft(c, &draft) {
+		return
+	}
+	created, err := services.CreateDraft(&draft)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to create draft")
+		return
+	}
+	utils.SuccessResponse(c, created)
+}
+
+// SaveInspectionDraft 自动保存草稿，整体替换表单内容
+// 提供 version 参数时，只有与服务端版本一致才保存，否则返回 409，客户端应重新加载草稿
+func SaveInspectionDraft(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid draft id")
+		return
+	}
+	version, _ := strconv.Atoi(c.Query("version"))
+
+	draft := models.InspectionDraft{ID: id, UserID: currentUserID(c)}
+	if !bindDraft(c, &draft) {
+		return
+	}
+	saved, err := services.SaveDraft(&draft, version)
+	if err != nil {
+		respondDraftError(c, err, "failed to save draft")
+		return
+	}
+	utils.SuccessResponse(c, saved)
+}
+
+// DeleteInspectionDraft 删除草稿
+func DeleteInspectionDraft(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid draft id")
+		return
+	}
+	if err := services.DeleteDraft(id, currentUserID(c)); err != nil {
+		respondDraftError(c, err, "failed to delete draft")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"message": "draft deleted"})
+}
+
+// SubmitInspectionDraft 提交草稿，按新增点检记录的全部规则校验后转为正式记录
+func SubmitInspectionDraft(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid draft id")
+		return
+	}
+	draft, err := services.GetUserDraft(id, currentUserID(c))
+	if err != nil {
+		respondDraftError(c, err, "failed to get draft")
+		return
+	}
+
+	var requestData InspectionRequest
+	if err := json.Unmarshal(draft.Data, &requestData); err != nil {
+		utils.ErrorResponse(c, "invalid draft: "+err.Error())
+		return
+	}
+	if err := binding.Validator.ValidateStruct(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	record, ok := newRecordFromRequest(c, &requestData)
+	if !ok {
+		return
+	}
+	created, err := services.SubmitDraft(draft, record)
+	if err != nil {
+		respondDraftError(c, err, "failed to submit draft")
+		return
+	}
+	utils.SuccessResponse(c, created)
+}
@@ -8,28 +8,16 @@ import (
 	"strconv"
 	"time"
 
+	"DLM_backend/models"
 	"DLM_backend/services"
 	"DLM_backend/utils"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 	"github.com/xuri/excelize/v2"
 )
 
-// ExportInspection 导出点检记录为Excel文件
+// ExportInspection 导出点检记录为Excel文件，approved_only=true 时只导出审核通过的记录
 func ExportInspection(c *gin.Context) {
-	// 检查用户角色是否为管理员
-	claims, exists := c.Get("claims")
-	if !exists {
-		utils.UnauthorizedResponse(c, "token claims not found")
-		return
-	}
-	role := claims.(jwt.MapClaims)["role"].(string)
-	if role != "admin" {
-		utils.UnauthorizedResponse(c, "only admin can export records")
-		return
-	}
-
 	// 创建Excel文件
 	f := excelize.NewFile()
 	defer func() {
@@ -47,136 +35,109 @@ func ExportInspection(c *gin.Context) {
 	}
 	f.SetActiveSheet(index)
 
-	// 设置表头
-	headers := []string{
-		"ID", "单位", "仓号", "挡粮门位置", "保管责任人",
-		"检查时间", "变形和裂痕情况", "变形和裂痕说明",
-		"闭合情况", "闭合说明", "栓销状况", "栓销说明",
-		"主体墙状况", "主体墙说明", "仓门地基状况", "地基说明",
-		"安全绳装置", "安全绳说明", "补充说明", "责任人签名",
-		"联系电话", "图片列表",
+	// 获取当前用户可访问单位范围内的所有记录
+	filters := make(map[string]interface{})
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return
 	}
-	for i, header := range headers {
-		cell := string(rune('A'+i)) + "1"
-		f.SetCellValue(sheetName, cell, header)
+	if scope != nil {
+		filters["unit_scope"] = scope
 	}
-
-	// 获取所有记录
-	records, err := services.GetInspectionRecords()
+	if approvedOnly, _ := strconv.ParseBool(c.Query("approved_only")); approvedOnly {
+		filters["review_status"] = models.ReviewApproved
+	}
+	records, err := services.GetInspectionRecords(filters)
 	if err != nil {
 		utils.ServerErrorResponse(c, "failed to get records")
 		return
 	}
+	reviewerNames, err := services.ReviewerNames(records)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get reviewers")
+		return
+	}
+
+	// 点检项列由记录使用的各模板版本决定，没有记录时按当前启用的模板
+	var templateIDs []int
+	for _, record := range records {
+		if record.TemplateID != nil {
+			templateIDs = append(templateIDs, *record.TemplateID)
+		}
+	}
+	if len(templateIDs) == 0 {
+		if active, err := services.GetActiveChecklistTemplate(); err == nil {
+			templateIDs = append(templateIDs, active.ID)
+		}
+	}
+	templates, err := services.GetChecklistTemplatesByIDs(templateIDs)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get checklist templates")
+		return
+	}
+	items := services.ExportChecklistItems(templates)
+
+	// 设置表头
+	headers := []string{"ID", "单位", "仓号", "挡粮门位置", "保管责任人", "检查时间"}
+	for _, item := range items {
+		headers = append(headers, item.Label, item.Label+"说明")
+	}
+	headers = append(headers,
+		"补充说明", "责任人签名", "联系电话", "图片列表", "提交时间", "最后修改时间",
+		"扫码点检", "审核状态", "审核人", "审核时间",
+	)
+	for i, header := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(sheetName, cell, header)
+	}
 
 	// 写入数据
 	for i, record := range records {
 		row := i + 2 // 第一行是表头
-		f.SetCellValue(sheetName, "A"+strconv.Itoa(row), record.ID)
-		f.SetCellValue(sheetName, "B"+strconv.Itoa(row), record.Unit)
-		f.SetCellValue(sheetName, "C"+strconv.Itoa(row), record.WarehouseNumber)
-		f.SetCellValue(sheetName, "D"+strconv.Itoa(row), record.GrainDoorPosition)
-		f.SetCellValue(sheetName, "E"+strconv.Itoa(row), record.Caretaker)
-		f.SetCellValue(sheetName, "F"+strconv.Itoa(row), record.InspectionTime.Format("2006-01-02 15:04:05"))
-		f.SetCellValue(sheetName, "G"+strconv.Itoa(row), record.DeformationCrack)
-		f.SetCellValue(sheetName, "H"+strconv.Itoa(row), record.DeformationCrackDescription)
-		f.SetCellValue(sheetName, "I"+strconv.Itoa(row), record.ClosureStatus)
-		f.SetCellValue(sheetName, "J"+strconv.Itoa(row), record.ClosureDescription)
-
-		// 处理栓销状态
-		// 'normal': '正常',
-		// 'loose': '松动',
-		// 'deformed': '变形',
-		// 'missing': '缺失',
-		var pinStatus []string
-		if err := json.Unmarshal([]byte(record.PinStatus), &pinStatus); err == nil {
-			pinStatusStr := ""
-			for _, status := range pinStatus {
-				switch status {
-				case "normal":
-					pinStatusStr += "正常"
-				case "loose":
-					pinStatusStr += "松动"
-				case "deformed":
-					pinStatusStr += "变形"
-				case "missing":
-					pinStatusStr += "缺失"
-				}
-				pinStatusStr += ","
+		col := 0
+		setCell := func(value interface{}) {
+			col++
+			cell, _ := excelize.CoordinatesToCellName(col, row)
+			if value != nil {
+				f.SetCellValue(sheetName, cell, value)
 			}
-			// 去掉最后一个逗号
-			pinStatusStr = pinStatusStr[:len(pinStatusStr)-1]
-			f.SetCellValue(sheetName, "K"+strconv.Itoa(row), pinStatusStr)
-		} else {
-			// 如果解析失败，使用原始字符串
-			f.SetCellValue(sheetName, "K"+strconv.Itoa(row), string(record.PinStatus))
 		}
-
-		f.SetCellValue(sheetName, "L"+strconv.Itoa(row), record.PinDescription)
-
-		// 处理主体墙状态
-		// 'normal': '正常',
-		// 'damaged': '破损',
-		// 'cracked': '有裂缝',
-
-		var mainWallStatus []string
-		if err := json.Unmarshal([]byte(record.MainWallStatus), &mainWallStatus); err == nil {
-			mainWallStatusStr := ""
-			for _, status := range mainWallStatus {
-				switch status {
-				case "normal":
-					mainWallStatusStr += "正常"
-				case "damaged":
-					mainWallStatusStr += "破损"
-				case "cracked":
-					mainWallStatusStr += "有裂缝"
-				}
-				mainWallStatusStr += ","
-			}
-			// 去掉最后一个逗号
-			mainWallStatusStr = mainWallStatusStr[:len(mainWallStatusStr)-1]
-			f.SetCellValue(sheetName, "M"+strconv.Itoa(row), mainWallStatusStr)
-		} else {
-			// 如果解析失败，使用原始字符串
-			f.SetCellValue(sheetName, "M"+strconv.Itoa(row), string(record.MainWallStatus))
+		setCell(record.ID)
+		setCell(record.Unit)
+		setCell(record.WarehouseNumber)
+		setCell(record.GrainDoorPosition)
+		setCell(record.Caretaker)
+		setCell(record.InspectionTime.Format("2006-01-02 15:04:05"))
+
+		// 状态编码按记录所用模板版本转换为显示名称，该版本没有的点检项留空
+		answers, err := record.ChecklistAnswers()
+		if err != nil || len(answers) == 0 {
+			answers = record.LegacyAnswers()
 		}
-
-		f.SetCellValue(sheetName, "N"+strconv.Itoa(row), record.MainWallDescription)
-
-		// 处理仓门地基状态
-		// 'normal': '正常',
-		// 'frozen': '冻胀',
-		// 'sinking': '下沉',
-		// 'collapsed': '塌陷'
-		var foundationStatus []string
-		if err := json.Unmarshal([]byte(record.WarehouseFoundation), &foundationStatus); err == nil {
-			foundationStatusStr := ""
-			for _, status := range foundationStatus {
-				switch status {
-				case "normal":
-					foundationStatusStr += "正常"
-				case "frozen":
-					foundationStatusStr += "冻胀"
-				case "sinking":
-					foundationStatusStr += "下沉"
-				case "collapsed":
-					foundationStatusStr += "塌陷"
+		var template *models.ChecklistTemplate
+		if record.TemplateID != nil {
+			template = templates[*record.TemplateID]
+		}
+		for _, item := range items {
+			answer, ok := answers[item.Field]
+			if !ok {
+				setCell(nil)
+				setCell(nil)
+				continue
+			}
+			if template != nil {
+				if recordItem, found := template.FindItem(item.Field); found {
+					item = recordItem
 				}
-				foundationStatusStr += ","
 			}
-			// 去掉最后一个逗号
-			foundationStatusStr = foundationStatusStr[:len(foundationStatusStr)-1]
-			f.SetCellValue(sheetName, "O"+strconv.Itoa(row), foundationStatusStr)
-		} else {
-			// 如果解析失败，使用原始字符串
-			f.SetCellValue(sheetName, "O"+strconv.Itoa(row), string(record.WarehouseFoundation))
+			setCell(services.AnswerLabels(item, answer))
+			setCell(answer.Description)
 		}
 
-		f.SetCellValue(sheetName, "P"+strconv.Itoa(row), record.WarehouseFoundationDescription)
-		f.SetCellValue(sheetName, "Q"+strconv.Itoa(row), record.SafetyRopeInstalled)
-		f.SetCellValue(sheetName, "R"+strconv.Itoa(row), record.SafetyRopeDescription)
-		f.SetCellValue(sheetName, "S"+strconv.Itoa(row), record.Remarks)
-		f.SetCellValue(sheetName, "T"+strconv.Itoa(row), record.Signature)
-		f.SetCellValue(sheetName, "U"+strconv.Itoa(row), record.ContactNumber)
+		setCell(record.Remarks)
+		setCell(record.Signature)
+		setCell(record.ContactNumber)
 
 		// 处理图片路径
 		var imagePaths []string
@@ -204,16 +165,48 @@ func ExportInspection(c *gin.Context) {
 				imagePathsStr = imagePathsStr[:len(imagePathsStr)-1]
 			}
 
-			f.SetCellValue(sheetName, "V"+strconv.Itoa(row), imagePathsStr)
+			setCell(imagePathsStr)
 		} else {
 			// 如果解析失败，使用原始字符串
-			f.SetCellValue(sheetName, "V"+strconv.Itoa(row), string(record.Images))
+			setCell(string(record.Images))
+		}
+
+		// 提交时间和最后修改时间，历史数据可能为空
+		if !record.CreatedAt.IsZero() {
+			setCell(record.CreatedAt.Format("2006-01-02 15:04:05"))
+		} else {
+			setCell(nil)
+		}
+		if !record.UpdatedAt.IsZero() {
+			setCell(record.UpdatedAt.Format("2006-01-02 15:04:05"))
+		} else {
+			setCell(nil)
+		}
+
+		// 是否现场扫描挡粮门二维码
+		qrVerified := "否"
+		if record.QRVerified {
+			qrVerified = "是"
+		}
+		setCell(qrVerified)
+
+		// 审核状态、审核人和审核时间
+		setCell(models.ReviewStatusLabels[record.ReviewStatus])
+		if record.ReviewerID != nil {
+			setCell(reviewerNames[*record.ReviewerID])
+		} else {
+			setCell(nil)
+		}
+		if record.ReviewedAt != nil {
+			setCell(record.ReviewedAt.Format("2006-01-02 15:04:05"))
+		} else {
+			setCell(nil)
 		}
 	}
 
 	// 调整列宽
 	for i := 0; i < len(headers); i++ {
-		col := string(rune('A' + i))
+		col, _ := excelize.ColumnNumberToName(i + 1)
 		f.SetColWidth(sheetName, col, col, 15)
 	}
 
@@ -1,45 +1,274 @@
 package controllers
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
 	"os"
+	"path"
 	"strconv"
+	"strings"
 	"time"
 
+	"DLM_backend/config"
+	"DLM_backend/models"
+	"DLM_backend/services"
 	"DLM_backend/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-// UploadImage 处理图片上传请求
+// imageFS 提供上传图片访问的文件系统，不列出目录内容
+var imageFS = gin.Dir(config.ImageUploadDir, false)
+
+// servedImageExts 允许访问的图片扩展名，缩略版本统一为 .jpg
+// 校验上线前上传的其他文件（例如 .html、.svg）不再提供访问，避免在接口域名下执行脚本
+var servedImageExts = map[string]bool{".jpg": true, ".png": true, ".webp": true}
+
+// ServeImage 提供上传图片的访问，size 参数为 thumb 或 medium 时返回对应的缩略版本
+// 原图较小或历史图片没有缩略版本时返回原图，因此记录中的图片路径都可以直接加上 size 参数
+// 只提供扩展名和文件内容都是允许格式的图片，Content-Type 按文件内容确定并禁止浏览器猜测类型
+func ServeImage(c *gin.Context) {
+	name := path.Clean("/" + c.Param("filepath"))
+	if path.Dir(name) != "/" || !servedImageExts[strings.ToLower(path.Ext(name))] {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if size := c.Query("size"); size == services.ImageVariantThumb || size == services.ImageVariantMedium {
+		variant := services.ImageVariantFilename(name, size)
+		if f, err := imageFS.Open(variant); err == nil {
+			f.Close()
+			name = variant
+		}
+	}
+
+	f, err := imageFS.Open(name)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil || stat.IsDir() {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	header := make([]byte, 12)
+	n, _ := io.ReadFull(f, header)
+	contentType, _ := services.ImageContentType(header[:n])
+	if contentType == "" {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	http.ServeContent(c.Writer, c.Request, name, stat.ModTime(), f)
+}
+
+// UploadImage 处理图片上传请求，可同时提供 caption（图片说明）和 captured_at（拍摄时间，RFC3339）
+// 只接受 JPEG、PNG 和 WebP 图片，校验未通过时返回 code 错误码
+// 上传的图片在点检记录保存时按图片列表关联到记录，同时生成缩略图和中图
 func UploadImage(c *gin.Context) {
 	// 获取上传的文件
-	file, err := c.FormFile("file")
+	limitUploadBody(c)
+	file, err := formImageFile(c, "file")
 	if err != nil {
-		utils.ErrorResponse(c, "未能获取上传文件: "+err.Error())
+		respondFormFileError(c, err)
 		return
 	}
 
+	attachment, err := newAttachmentFromForm(c)
+	if err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+	if err := saveUploadedImage(c, file, attachment); err != nil {
+		respondImageError(c, err)
+		return
+	}
+
+	// 返回文件访问路径
+	utils.SuccessResponse(c, gin.H{
+		"url":        attachment.URL,
+		"filename":   attachment.Filename,
+		"attachment": attachment,
+	})
+}
+
+// newAttachmentFromForm 读取表单中的图片说明和拍摄时间
+func newAttachmentFromForm(c *gin.Context) (*models.Attachment, error) {
+	attachment := &models.Attachment{Caption: c.PostForm("caption")}
+	if capturedAt := c.PostForm("captured_at"); capturedAt != "" {
+		t, err := time.Parse(time.RFC3339, capturedAt)
+		if err != nil {
+			return nil, errors.New("invalid captured_at, expected RFC3339 format")
+		}
+		attachment.CapturedAt = &t
+	}
+	return attachment, nil
+}
+
+// saveUploadedImage 校验并保存上传的图片，登记为附件，附件的访问路径、大小和摘要由此填写
+// 文件类型按内容识别，文件名由服务器生成，不使用客户端提供的文件名
+func saveUploadedImage(c *gin.Context, file *multipart.FileHeader, attachment *models.Attachment) error {
+	src, err := file.Open()
+	if err != nil {
+		return errors.New("读取文件失败: " + err.Error())
+	}
+	defer src.Close()
+
+	info, err := services.ValidateImage(src, file.Size)
+	if err != nil {
+		return err
+	}
+
 	// 创建存储目录（如果不存在）
-	uploadDir := "uploads/images"
+	uploadDir := config.ImageUploadDir
 	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		utils.ServerErrorResponse(c, "创建目录失败: "+err.Error())
-		return
+		return errors.New("创建目录失败: " + err.Error())
 	}
 
 	// 生成唯一文件名（防止文件名冲突）
-	filename := strconv.FormatInt(time.Now().UnixNano(), 10) + "_" + file.Filename
+	filename, err := newImageFilename(info.Ext)
+	if err != nil {
+		return errors.New("生成文件名失败: " + err.Error())
+	}
 	filepath := uploadDir + "/" + filename
 
-	// 保存文件
-	if err := c.SaveUploadedFile(file, filepath); err != nil {
-		utils.ServerErrorResponse(c, "保存文件失败: "+err.Error())
+	// 保存文件，同时计算内容摘要
+	hash, err := writeUploadedFile(src, filepath)
+	if err != nil {
+		return errors.New("保存文件失败: " + err.Error())
+	}
+
+	// 生成缩略图和中图，列表等场景可以只加载缩略版本
+	variants, err := services.GenerateImageVariants(uploadDir, filename)
+	if err != nil {
+		_ = os.Remove(filepath)
+		return err
+	}
+
+	attachment.URL = "/images/" + filename
+	attachment.ThumbURL = imageVariantURL(filename, variants[services.ImageVariantThumb])
+	attachment.MediumURL = imageVariantURL(filename, variants[services.ImageVariantMedium])
+	attachment.Filename = filename
+	attachment.ContentType = info.ContentType
+	attachment.Size = file.Size
+	attachment.Width = info.Width
+	attachment.Height = info.Height
+	attachment.Hash = hash
+	if userID := currentUserID(c); userID > 0 {
+		attachment.UploaderID = &userID
+	}
+	if err := services.CreateAttachment(attachment); err != nil {
+		_ = os.Remove(filepath)
+		services.RemoveImageVariants(uploadDir, filename)
+		return errors.New("登记附件失败: " + err.Error())
+	}
+	return nil
+}
+
+// removeUploadedImages 删除已保存但未被使用的上传图片，包括原图、缩略版本和附件登记
+func removeUploadedImages(attachments []*models.Attachment) {
+	ids := make([]int, 0, len(attachments))
+	for _, attachment := range attachments {
+		_ = os.Remove(config.ImageUploadDir + "/" + attachment.Filename)
+		services.RemoveImageVariants(config.ImageUploadDir, attachment.Filename)
+		ids = append(ids, attachment.ID)
+	}
+	_ = services.DeleteUnlinkedAttachments(ids)
+}
+
+// imageVariantURL 返回缩略版本的访问路径，没有生成该版本时使用原图
+func imageVariantURL(filename, variant string) string {
+	if variant == "" {
+		return "/images/" + filename
+	}
+	return "/images/" + variant
+}
+
+// newImageFilename 生成图片文件名：纳秒时间戳加随机串，扩展名按识别出的图片类型
+func newImageFilename(ext string) (string, error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(time.Now().UnixNano(), 10) + "_" + hex.EncodeToString(random) + ext, nil
+}
+
+// writeUploadedFile 将上传文件的内容写入 dst，返回内容的 SHA-256 摘要，写入失败时删除不完整的文件
+func writeUploadedFile(src io.Reader, dst string) (string, error) {
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(out, h), src)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// limitUploadBody 限制上传请求体的大小，超过图片大小限制的请求在读取表单时即被拒绝
+func limitUploadBody(c *gin.Context) {
+	if maxBytes := services.GetImagePolicy().MaxBytes; maxBytes > 0 {
+		// 为表单中的其他字段和分隔符预留空间
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+1<<20)
+	}
+}
+
+// formImageFile 读取表单中的图片文件，请求体超过大小限制时返回图片过大的错误
+func formImageFile(c *gin.Context, name string) (*multipart.FileHeader, error) {
+	file, err := c.FormFile(name)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, &services.ImageRejectedError{Code: services.ImageErrTooLarge,
+				Message: fmt.Sprintf("file exceeds the maximum size of %d bytes", services.GetImagePolicy().MaxBytes)}
+		}
+		return nil, errors.New("未能获取上传文件: " + err.Error())
+	}
+	return file, nil
+}
+
+// respondFormFileError 读取上传文件失败时返回响应，请求体过大时返回错误码
+func respondFormFileError(c *gin.Context, err error) {
+	var rejected *services.ImageRejectedError
+	if errors.As(err, &rejected) {
+		respondImageError(c, err)
 		return
 	}
+	utils.ErrorResponse(c, err.Error())
+}
 
-	// 返回文件访问路径
-	fileURL := "/images/" + filename
-	utils.SuccessResponse(c, gin.H{
-		"url":      fileURL,
-		"filename": filename,
-	})
+// respondImageError 按错误类型返回图片上传失败的响应，校验未通过时返回错误码
+func respondImageError(c *gin.Context, err error) {
+	var rejected *services.ImageRejectedError
+	if !errors.As(err, &rejected) {
+		utils.ServerErrorResponse(c, err.Error())
+		return
+	}
+	status := http.StatusBadRequest
+	switch rejected.Code {
+	case services.ImageErrTooLarge:
+		status = http.StatusRequestEntityTooLarge
+	case services.ImageErrUnsupportedType:
+		status = http.StatusUnsupportedMediaType
+	}
+	utils.CodedErrorResponse(c, status, rejected.Code, rejected.Message)
 }
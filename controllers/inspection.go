@@ -2,7 +2,9 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"strconv"
+	"strings"
 	"time"
 
 	"DLM_backend/database"
@@ -17,27 +19,154 @@ import (
 
 // InspectionRequest 用于处理前端传来的点检记录请求
 type InspectionRequest struct {
-	Unit                           string         `json:"unit" binding:"required"`                  // 单位
-	WarehouseNumber                string         `json:"warehouse_number" binding:"required"`      // 仓号
-	GrainDoorPosition              string         `json:"grain_door_position" binding:"required"`   // 挡粮门位置
-	Caretaker                      string         `json:"caretaker" binding:"required"`             // 保管责任人
-	InspectionTime                 time.Time      `json:"inspection_time" binding:"required"`       // 检查时间
-	DeformationCrack               string         `json:"deformation_crack" binding:"required"`     // 挡粮门变形和裂痕情况
-	DeformationCrackDescription    string         `json:"deformation_crack_description"`            // 挡粮门变形和裂痕情况说明
-	ClosureStatus                  string         `json:"closure_status" binding:"required"`        // 闭合情况
-	ClosureDescription             string         `json:"closure_description"`                      // 闭合情况说明
-	PinStatus                      datatypes.JSON `json:"pin_status" binding:"required"`            // 栓销状况
-	PinDescription                 string         `json:"pin_description"`                          // 栓销状况说明
-	MainWallStatus                 datatypes.JSON `json:"main_wall_status" binding:"required"`      // 主体墙状况
-	MainWallDescription            string         `json:"main_wall_description"`                    // 主体墙状况说明
-	WarehouseFoundation            datatypes.JSON `json:"warehouse_foundation" binding:"required"`  // 仓门地基状况
-	WarehouseFoundationDescription string         `json:"warehouse_foundation_description"`         // 仓门地基状况说明
-	SafetyRopeInstalled            string         `json:"safety_rope_installed" binding:"required"` // 安全绳（带）系留装置
-	SafetyRopeDescription          string         `json:"safety_rope_description"`                  // 安全绳（带）系留装置说明
-	Remarks                        string         `json:"remarks"`                                  // 补充说明
-	Signature                      string         `json:"signature" binding:"required"`             // 责任人签名
-	ContactNumber                  string         `json:"contact_number" binding:"required"`        // 联系电话
-	Images                         datatypes.JSON `json:"images"`                                   // 图片列表
+	UnitID                         *int                              `json:"unit_id"`                                // 单位ID，不提供时按单位名称匹配
+	GrainDoorID                    *int                              `json:"grain_door_id"`                          // 挡粮门ID，提供时以挡粮门主数据为准
+	QRToken                        string                            `json:"qr_token"`                               // 现场扫描的挡粮门二维码，提供时记录标记为扫码点检
+	Unit                           string                            `json:"unit" binding:"required"`                // 单位
+	WarehouseNumber                string                            `json:"warehouse_number" binding:"required"`    // 仓号
+	GrainDoorPosition              string                            `json:"grain_door_position" binding:"required"` // 挡粮门位置
+	Caretaker                      string                            `json:"caretaker" binding:"required"`           // 保管责任人
+	InspectionTime                 time.Time                         `json:"inspection_time" binding:"required"`     // 检查时间
+	DeformationCrack               string                            `json:"deformation_crack"`                      // 挡粮门变形和裂痕情况
+	DeformationCrackDescription    string                            `json:"deformation_crack_description"`          // 挡粮门变形和裂痕情况说明
+	ClosureStatus                  string                            `json:"closure_status"`                         // 闭合情况
+	ClosureDescription             string                            `json:"closure_description"`                    // 闭合情况说明
+	PinStatus                      datatypes.JSON                    `json:"pin_status"`                             // 栓销状况
+	PinDescription                 string                            `json:"pin_description"`                        // 栓销状况说明
+	MainWallStatus                 datatypes.JSON                    `json:"main_wall_status"`                       // 主体墙状况
+	MainWallDescription            string                            `json:"main_wall_description"`                  // 主体墙状况说明
+	WarehouseFoundation            datatypes.JSON                    `json:"warehouse_foundation"`                   // 仓门地基状况
+	WarehouseFoundationDescription string                            `json:"warehouse_foundation_description"`       // 仓门地基状况说明
+	SafetyRopeInstalled            string                            `json:"safety_rope_installed"`                  // 安全绳（带）系留装置
+	SafetyRopeDescription          string                            `json:"safety_rope_description"`                // 安全绳（带）系留装置说明
+	Remarks                        string                            `json:"remarks"`                                // 补充说明
+	Signature                      string                            `json:"signature" binding:"required"`           // 责任人签名
+	ContactNumber                  string                            `json:"contact_number" binding:"required"`      // 联系电话
+	Images                         datatypes.JSON                    `json:"images"`                                 // 图片列表
+	Answers                        map[string]models.ChecklistAnswer `json:"answers"`                                // 按点检模板填写的各点检项结果，提供时以此为准，否则使用上面的固定字段
+}
+
+// applyTo 将请求中的点检内容写入记录模型，不修改记录的ID、归属用户和提交时间
+func (r *InspectionRequest) applyTo(record *models.InspectionRecord) {
+	// 处理数组字段转换为JSON
+	pinStatusJSON, _ := json.Marshal(r.PinStatus)
+	mainWallStatusJSON, _ := json.Marshal(r.MainWallStatus)
+	warehouseFoundationJSON, _ := json.Marshal(r.WarehouseFoundation)
+
+	record.Unit = r.Unit
+	record.WarehouseNumber = r.WarehouseNumber
+	record.GrainDoorPosition = r.GrainDoorPosition
+	record.Caretaker = r.Caretaker
+	record.InspectionTime = r.InspectionTime
+	record.DeformationCrack = r.DeformationCrack
+	record.DeformationCrackDescription = r.DeformationCrackDescription
+	record.ClosureStatus = r.ClosureStatus
+	record.ClosureDescription = r.ClosureDescription
+	record.PinStatus = pinStatusJSON
+	record.PinDescription = r.PinDescription
+	record.MainWallStatus = mainWallStatusJSON
+	record.MainWallDescription = r.MainWallDescription
+	record.WarehouseFoundation = warehouseFoundationJSON
+	record.WarehouseFoundationDescription = r.WarehouseFoundationDescription
+	record.SafetyRopeInstalled = r.SafetyRopeInstalled
+	record.SafetyRopeDescription = r.SafetyRopeDescription
+	record.Remarks = r.Remarks
+	record.Signature = r.Signature
+	record.ContactNumber = r.ContactNumber
+
+	// 按模板填写的点检项结果，未提供时由服务层根据固定字段生成
+	// 模板版本不由客户端指定：新记录使用当前启用的模板，修改时保持记录原有的模板
+	record.Answers = nil
+	if len(r.Answers) > 0 {
+		answersJSON, _ := json.Marshal(r.Answers)
+		record.Answers = answersJSON
+	}
+
+	// 处理图片数据
+	if len(r.Images) > 0 {
+		// 转换图片数组为JSON
+		imagesJSON, _ := json.Marshal(r.Images)
+		record.Images = imagesJSON
+	}
+}
+
+// resolveUnit 确定记录所属单位和挡粮门，单位必须在当前用户可访问的范围内
+// 提供挡粮门ID时，单位、仓号和门位置以挡粮门主数据为准；未提供时按仓号和门位置匹配主数据，匹配不到时不关联挡粮门
+// 提供二维码时以二维码对应的挡粮门为准，并标记为扫码点检
+func (r *InspectionRequest) resolveUnit(record *models.InspectionRecord, scope []int) error {
+	if r.QRToken != "" {
+		door, err := services.VerifyDoorQRToken(r.QRToken, scope)
+		if err != nil {
+			return err
+		}
+		if r.GrainDoorID != nil && *r.GrainDoorID != door.ID {
+			return services.ErrQRTokenMismatch
+		}
+		r.GrainDoorID = &door.ID
+		record.SetQRScan(r.QRToken, door.QRVersion)
+	} else if r.GrainDoorID != nil && (record.GrainDoorID == nil || *record.GrainDoorID != *r.GrainDoorID) {
+		// 未扫码更换了挡粮门，原扫码结果不再适用
+		record.ClearQRScan()
+	}
+
+	if r.GrainDoorID != nil {
+		door, err := services.ResolveRecordDoor(*r.GrainDoorID, scope)
+		if err != nil {
+			return err
+		}
+		record.GrainDoorID = &door.ID
+		record.UnitID = &door.Warehouse.UnitID
+		if door.Warehouse.Unit != nil {
+			record.Unit = door.Warehouse.Unit.Name
+		}
+		record.WarehouseNumber = door.Warehouse.Number
+		record.GrainDoorPosition = door.Position
+		return nil
+	}
+
+	unit, err := services.ResolveRecordUnit(r.UnitID, r.Unit, scope)
+	if err != nil {
+		return err
+	}
+	record.UnitID = &unit.ID
+	record.Unit = unit.Name
+
+	door, err := services.FindRecordDoor(unit.ID, record.WarehouseNumber, record.GrainDoorPosition)
+	if err != nil {
+		return err
+	}
+	var doorID *int
+	if door != nil {
+		doorID = &door.ID
+	}
+	if record.GrainDoorID == nil || doorID == nil || *record.GrainDoorID != *doorID {
+		// 仓号或门位置改到了其他挡粮门，原扫码结果不再适用
+		record.ClearQRScan()
+	}
+	record.GrainDoorID = doorID
+	return nil
+}
+
+// respondRecordError 将点检记录服务层错误转换为响应
+func respondRecordError(c *gin.Context, err error, fallback string) {
+	var fieldErr *services.FieldError
+	switch {
+	case errors.As(err, &fieldErr):
+		utils.FieldErrorResponse(c, fieldErr.Field, fieldErr.Message)
+	case errors.Is(err, services.ErrRecordNotFound):
+		utils.NotFoundResponse(c, err.Error())
+	case errors.Is(err, services.ErrRecordModified):
+		utils.ConflictResponse(c, err.Error())
+	case errors.Is(err, services.ErrRecordNotOwned), errors.Is(err, services.ErrEditWindowExpired),
+		errors.Is(err, services.ErrUnitNotAccessible):
+		utils.ForbiddenResponse(c, err.Error())
+	case errors.Is(err, services.ErrUnitNotFound), errors.Is(err, services.ErrGrainDoorNotFound),
+		errors.Is(err, services.ErrInvalidQRToken), errors.Is(err, services.ErrQRTokenMismatch),
+		errors.Is(err, services.ErrNotAwaitingReview):
+		utils.ErrorResponse(c, err.Error())
+	default:
+		utils.ErrorResponse(c, fallback)
+	}
 }
 
 // CreateInspection 处理新增点检记录请求
@@ -48,64 +177,51 @@ func CreateInspection(c *gin.Context) {
 		return
 	}
 
-	// 从JWT获取用户信息
-	claims, exists := c.Get("claims")
-	if !exists {
-		utils.UnauthorizedResponse(c, "token claims not found")
+	record, ok := newRecordFromRequest(c, &requestData)
+	if !ok {
 		return
 	}
-	username := claims.(jwt.MapClaims)["username"].(string)
-
-	// 查询用户ID
-	var user models.User
-	if err := database.DB.Where("username = ?", username).First(&user).Error; err != nil {
-		utils.NotFoundResponse(c, "user not found")
+	created, err := services.CreateInspectionRecord(record)
+	if err != nil {
+		respondRecordError(c, err, "failed to create record")
 		return
 	}
+	utils.SuccessResponse(c, created)
+}
 
-	// 处理数组字段转换为JSON
-	pinStatusJSON, _ := json.Marshal(requestData.PinStatus)
-	mainWallStatusJSON, _ := json.Marshal(requestData.MainWallStatus)
-	warehouseFoundationJSON, _ := json.Marshal(requestData.WarehouseFoundation)
+// newRecordFromRequest 根据请求内容生成属于当前用户的新点检记录并确定所属单位，失败时已写入错误响应
+func newRecordFromRequest(c *gin.Context, requestData *InspectionRequest) (*models.InspectionRecord, bool) {
+	// 获取JWT中间件加载的当前用户
+	user := utils.CurrentUser(c)
+	if user == nil {
+		utils.UnauthorizedResponse(c, "token claims not found")
+		return nil, false
+	}
 
-	// 转换为模型
-	record := models.InspectionRecord{
-		UserID:                         user.ID, // 添加用户ID
-		Unit:                           requestData.Unit,
-		WarehouseNumber:                requestData.WarehouseNumber,
-		GrainDoorPosition:              requestData.GrainDoorPosition,
-		Caretaker:                      requestData.Caretaker,
-		InspectionTime:                 requestData.InspectionTime,
-		DeformationCrack:               requestData.DeformationCrack,
-		DeformationCrackDescription:    requestData.DeformationCrackDescription,
-		ClosureStatus:                  requestData.ClosureStatus,
-		ClosureDescription:             requestData.ClosureDescription,
-		PinStatus:                      pinStatusJSON,
-		PinDescription:                 requestData.PinDescription,
-		MainWallStatus:                 mainWallStatusJSON,
-		MainWallDescription:            requestData.MainWallDescription,
-		WarehouseFoundation:            warehouseFoundationJSON,
-		WarehouseFoundationDescription: requestData.WarehouseFoundationDescription,
-		SafetyRopeInstalled:            requestData.SafetyRopeInstalled,
-		SafetyRopeDescription:          requestData.SafetyRopeDescription,
-		Remarks:                        requestData.Remarks,
-		Signature:                      requestData.Signature,
-		ContactNumber:                  requestData.ContactNumber,
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return nil, false
 	}
 
-	// 处理图片数据
-	if len(requestData.Images) > 0 {
-		// 转换图片数组为JSON
-		imagesJSON, _ := json.Marshal(requestData.Images)
-		record.Images = imagesJSON
+	// 转换为模型
+	record := models.InspectionRecord{UserID: user.ID}
+	requestData.applyTo(&record)
+	if err := requestData.resolveUnit(&record, scope); err != nil {
+		respondRecordError(c, err, "failed to resolve unit")
+		return nil, false
 	}
+	return &record, true
+}
 
-	created, err := services.CreateInspectionRecord(&record)
+// GetInspectionStatuses 获取当前启用模板的点检项及允许的状态编码，供小程序渲染选项
+func GetInspectionStatuses(c *gin.Context) {
+	template, err := services.GetActiveChecklistTemplate()
 	if err != nil {
-		utils.ErrorResponse(c, "failed to create record")
+		utils.ServerErrorResponse(c, "failed to get checklist template")
 		return
 	}
-	utils.SuccessResponse(c, created)
+	utils.SuccessResponse(c, template.Items)
 }
 
 // GetInspections 处理查询点检记录请求，支持分页和过滤
@@ -122,8 +238,28 @@ func GetInspections(c *gin.Context) {
 		pageSize = 10 // 限制pageSize范围，防止请求过大数据
 	}
 
-	// 构建过滤条件
+	// 构建过滤条件，限制在当前用户可访问的单位范围内
 	filters := make(map[string]interface{})
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return
+	}
+	if scope != nil {
+		filters["unit_scope"] = scope
+	}
+	if unitID, err := strconv.Atoi(c.Query("unit_id")); err == nil {
+		filters["unit_id"] = unitID
+	}
+	if warehouseID, err := strconv.Atoi(c.Query("warehouse_id")); err == nil {
+		filters["warehouse_id"] = warehouseID
+	}
+	if doorID, err := strconv.Atoi(c.Query("grain_door_id")); err == nil {
+		filters["grain_door_id"] = doorID
+	}
+	if verified, err := strconv.ParseBool(c.Query("qr_verified")); err == nil {
+		filters["qr_verified"] = verified
+	}
 
 	// 添加常规字段过滤
 	if unit := c.Query("unit"); unit != "" {
@@ -158,6 +294,37 @@ func GetInspections(c *gin.Context) {
 		}
 	}
 
+	// 添加提交时间、修改时间和操作人过滤，用于发现补录或事后修改的记录
+	for _, key := range []string{"created_start", "created_end", "updated_start", "updated_end"} {
+		if dateStr := c.Query(key); dateStr != "" {
+			date, err := time.Parse("2006-01-02", dateStr)
+			if err == nil {
+				if strings.HasSuffix(key, "_end") {
+					// 设置为当天结束时间
+					date = date.Add(24*time.Hour - time.Second)
+				}
+				filters[key] = date
+			}
+		}
+	}
+	if createdBy, err := strconv.Atoi(c.Query("created_by")); err == nil {
+		filters["created_by"] = createdBy
+	}
+	if updatedBy, err := strconv.Atoi(c.Query("updated_by")); err == nil {
+		filters["updated_by"] = updatedBy
+	}
+
+	// 审核状态过滤，多个状态用逗号分隔
+	if reviewStatus := c.Query("review_status"); reviewStatus != "" {
+		filters["review_status"] = reviewStatus
+	}
+
+	// 排序：sort_by 可选 inspection_time/created_at/updated_at/id，sort_order 可选 asc/desc
+	if sortBy := c.Query("sort_by"); sortBy != "" {
+		filters["sort_by"] = sortBy
+		filters["sort_order"] = c.DefaultQuery("sort_order", "desc")
+	}
+
 	// 添加状况类型过滤
 	if deformation := c.Query("deformation_crack"); deformation != "" {
 		filters["deformation_crack"] = deformation
@@ -323,46 +490,43 @@ func UpdateInspection(c *gin.Context) {
 		return
 	}
 
-	// 处理数组字段转换为JSON
-	pinStatusJSON, _ := json.Marshal(requestData.PinStatus)
-	mainWallStatusJSON, _ := json.Marshal(requestData.MainWallStatus)
-	warehouseFoundationJSON, _ := json.Marshal(requestData.WarehouseFoundation)
+	user := utils.CurrentUser(c)
+	if user == nil {
+		utils.UnauthorizedResponse(c, "token claims not found")
+		return
+	}
 
-	// 转换为模型
-	record := models.InspectionRecord{
-		ID:                             recordID,
-		Unit:                           requestData.Unit,
-		WarehouseNumber:                requestData.WarehouseNumber,
-		GrainDoorPosition:              requestData.GrainDoorPosition,
-		Caretaker:                      requestData.Caretaker,
-		InspectionTime:                 requestData.InspectionTime,
-		DeformationCrack:               requestData.DeformationCrack,
-		DeformationCrackDescription:    requestData.DeformationCrackDescription,
-		ClosureStatus:                  requestData.ClosureStatus,
-		ClosureDescription:             requestData.ClosureDescription,
-		PinStatus:                      pinStatusJSON,
-		PinDescription:                 requestData.PinDescription,
-		MainWallStatus:                 mainWallStatusJSON,
-		MainWallDescription:            requestData.MainWallDescription,
-		WarehouseFoundation:            warehouseFoundationJSON,
-		WarehouseFoundationDescription: requestData.WarehouseFoundationDescription,
-		SafetyRopeInstalled:            requestData.SafetyRopeInstalled,
-		SafetyRopeDescription:          requestData.SafetyRopeDescription,
-		Remarks:                        requestData.Remarks,
-		Signature:                      requestData.Signature,
-		ContactNumber:                  requestData.ContactNumber,
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return
 	}
 
-	// 处理图片数据
-	if len(requestData.Images) > 0 {
-		// 转换图片数组为JSON
-		imagesJSON, _ := json.Marshal(requestData.Images)
-		record.Images = imagesJSON
+	// 加载原记录并校验单位范围、归属和修改时间窗口
+	record, err := services.GetInspectionRecordByID(recordID)
+	if err == nil {
+		err = services.CheckRecordInScope(record, scope)
+	}
+	if err != nil {
+		respondRecordError(c, err, "failed to get record")
+		return
+	}
+	canUpdateAny := utils.HasPermission(c, models.PermInspectionUpdateAny)
+	if err := services.CheckRecordModifiable(record, user.ID, canUpdateAny); err != nil {
+		respondRecordError(c, err, "failed to update record")
+		return
 	}
 
-	updated, err := services.UpdateInspectionRecord(&record)
+	// 在原记录上应用修改，保留归属用户和提交时间
+	requestData.applyTo(record)
+	if err := requestData.resolveUnit(record, scope); err != nil {
+		respondRecordError(c, err, "failed to resolve unit")
+		return
+	}
+
+	updated, err := services.UpdateInspectionRecord(record, user.ID)
 	if err != nil {
-		utils.ErrorResponse(c, "failed to update record")
+		respondRecordError(c, err, "failed to update record")
 		return
 	}
 	utils.SuccessResponse(c, updated)
@@ -376,7 +540,35 @@ func DeleteInspection(c *gin.Context) {
 		utils.ErrorResponse(c, "invalid id")
 		return
 	}
-	if err := services.DeleteInspectionRecord(id); err != nil {
+
+	user := utils.CurrentUser(c)
+	if user == nil {
+		utils.UnauthorizedResponse(c, "token claims not found")
+		return
+	}
+
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return
+	}
+
+	// 校验单位范围、记录归属和删除时间窗口
+	record, err := services.GetInspectionRecordByID(id)
+	if err == nil {
+		err = services.CheckRecordInScope(record, scope)
+	}
+	if err != nil {
+		respondRecordError(c, err, "failed to get record")
+		return
+	}
+	canDeleteAny := utils.HasPermission(c, models.PermInspectionDeleteAny)
+	if err := services.CheckRecordModifiable(record, user.ID, canDeleteAny); err != nil {
+		respondRecordError(c, err, "failed to delete record")
+		return
+	}
+
+	if err := services.DeleteInspectionRecord(id, user.ID); err != nil {
 		utils.ErrorResponse(c, "failed to delete record")
 		return
 	}
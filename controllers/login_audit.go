@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"DLM_backend/services"
+	"DLM_backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListLoginAttempts 分页查询登录审计日志，支持按用户名、IP、结果和日期过滤
+func ListLoginAttempts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	// 确保参数有效
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	filters := make(map[string]interface{})
+	if username := c.Query("username"); username != "" {
+		filters["username"] = username
+	}
+	if ip := c.Query("ip"); ip != "" {
+		filters["ip"] = ip
+	}
+	if success := c.Query("success"); success != "" {
+		filters["success"] = success
+	}
+	if startDateStr := c.Query("start_date"); startDateStr != "" {
+		startDate, err := time.ParseInLocation("2006-01-02", startDateStr, time.Local)
+		if err == nil {
+			filters["start_date"] = startDate
+		}
+	}
+	if endDateStr := c.Query("end_date"); endDateStr != "" {
+		endDate, err := time.ParseInLocation("2006-01-02", endDateStr, time.Local)
+		if err == nil {
+			// 设置为当天结束时间
+			filters["end_date"] = endDate.Add(24*time.Hour - time.Second)
+		}
+	}
+
+	total, attempts, err := services.GetLoginAttemptsWithFilters(page, pageSize, filters)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get login attempts")
+		return
+	}
+
+	// 计算总页数
+	totalPages := (total + int64(pageSize) - 1) / int64(pageSize)
+
+	utils.SuccessResponse(c, gin.H{
+		"attempts": attempts,
+		"pagination": gin.H{
+			"total":      total,
+			"page":       page,
+			"pageSize":   pageSize,
+			"totalPages": totalPages,
+		},
+	})
+}
+
+// ListLoginLocks 获取当前被锁定的用户名和IP
+func ListLoginLocks(c *gin.Context) {
+	locks, err := services.GetActiveLoginLocks()
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get login locks")
+		return
+	}
+	utils.SuccessResponse(c, locks)
+}
+
+// UnlockLogin 管理员解除指定的锁定记录
+func UnlockLogin(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid lock id")
+		return
+	}
+	if err := services.UnlockLogin(id); err != nil {
+		if errors.Is(err, services.ErrLoginLockNotFound) {
+			utils.NotFoundResponse(c, err.Error())
+			return
+		}
+		utils.ServerErrorResponse(c, "failed to unlock login")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"message": "login unlocked"})
+}
+
+// UnlockUserLogin 管理员解除指定用户的登录锁定
+func UnlockUserLogin(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+	user, err := services.GetUserByID(id)
+	if err != nil {
+		respondUserError(c, err, "failed to get user")
+		return
+	}
+	if err := services.UnlockUserLogin(user.Username); err != nil {
+		utils.ServerErrorResponse(c, "failed to unlock login")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"message": "login unlocked"})
+}
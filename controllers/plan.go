@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"DLM_backend/models"
+	"DLM_backend/services"
+	"DLM_backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InspectionPlanRequest 新建/更新点检计划请求结构体
+type InspectionPlanRequest struct {
+	Name            string `json:"name"`                         // 计划名称
+	GrainDoorID     *int   `json:"grain_door_id"`                // 挡粮门ID，与仓房ID二选一
+	WarehouseID     *int   `json:"warehouse_id"`                 // 仓房ID，计划覆盖该仓房的所有挡粮门
+	AssigneeID      *int   `json:"assignee_id"`                  // 执行人ID，为空时由仓房的保管责任人执行
+	Frequency       string `json:"frequency" binding:"required"` // 平时点检频次：daily、weekly、monthly
+	SeasonFrequency string `json:"season_frequency"`             // 入粮季节点检频次
+	SeasonStart     string `json:"season_start"`                 // 入粮季节开始日期，格式 MM-DD
+	SeasonEnd       string `json:"season_end"`                   // 入粮季节结束日期，格式 MM-DD
+	StartDate       string `json:"start_date"`                   // 计划开始日期，格式 2006-01-02，默认当天
+	EndDate         string `json:"end_date"`                     // 计划结束日期，格式 2006-01-02
+	Enabled         *bool  `json:"enabled"`                      // 是否启用，默认启用
+	Remark          string `json:"remark"`                       // 备注
+}
+
+// applyTo 将请求内容写入点检计划模型
+func (r *InspectionPlanRequest) applyTo(plan *models.InspectionPlan) error {
+	plan.Name = r.Name
+	plan.GrainDoorID = r.GrainDoorID
+	plan.WarehouseID = r.WarehouseID
+	plan.AssigneeID = r.AssigneeID
+	plan.Frequency = r.Frequency
+	plan.SeasonFrequency = r.SeasonFrequency
+	plan.SeasonStart = r.SeasonStart
+	plan.SeasonEnd = r.SeasonEnd
+	plan.Remark = r.Remark
+	plan.Enabled = r.Enabled == nil || *r.Enabled
+
+	now := time.Now()
+	plan.StartDate = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if r.StartDate != "" {
+		startDate, err := time.ParseInLocation("2006-01-02", r.StartDate, time.Local)
+		if err != nil {
+			return errors.New("invalid start_date, expected format 2006-01-02")
+		}
+		plan.StartDate = startDate
+	}
+	plan.EndDate = nil
+	if r.EndDate != "" {
+		endDate, err := time.ParseInLocation("2006-01-02", r.EndDate, time.Local)
+		if err != nil {
+			return errors.New("invalid end_date, expected format 2006-01-02")
+		}
+		// 结束日期当天仍然有效
+		endDate = endDate.Add(24*time.Hour - time.Second)
+		plan.EndDate = &endDate
+	}
+	return nil
+}
+
+// respondPlanError 将点检计划服务层错误转换为响应
+func respondPlanError(c *gin.Context, err error, fallback string) {
+	var fieldErr *services.FieldError
+	switch {
+	case errors.As(err, &fieldErr):
+		utils.FieldErrorResponse(c, fieldErr.Field, fieldErr.Message)
+	case errors.Is(err, services.ErrPlanNotFound):
+		utils.NotFoundResponse(c, err.Error())
+	case errors.Is(err, services.ErrGrainDoorNotFound), errors.Is(err, services.ErrWarehouseNotFound),
+		errors.Is(err, services.ErrUserNotFound):
+		utils.ErrorResponse(c, err.Error())
+	default:
+		utils.ServerErrorResponse(c, fallback)
+	}
+}
+
+// ListInspectionPlans 获取点检计划列表，可按 warehouse_id 过滤
+func ListInspectionPlans(c *gin.Context) {
+	warehouseID, _ := strconv.Atoi(c.Query("warehouse_id"))
+	plans, err := services.GetInspectionPlans(warehouseID)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get inspection plans")
+		return
+	}
+	utils.SuccessResponse(c, plans)
+}
+
+// CreateInspectionPlan 新建点检计划
+func CreateInspectionPlan(c *gin.Context) {
+	var requestData InspectionPlanRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	var plan models.InspectionPlan
+	if err := requestData.applyTo(&plan); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+	plan.SetCreator(currentUserID(c))
+	created, err := services.CreateInspectionPlan(&plan)
+	if err != nil {
+		respondPlanError(c, err, "failed to create inspection plan")
+		return
+	}
+	utils.SuccessResponse(c, created)
+}
+
+// UpdateInspectionPlan 更新点检计划
+func UpdateInspectionPlan(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid inspection plan id")
+		return
+	}
+
+	var requestData InspectionPlanRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	plan, err := services.GetInspectionPlanByID(id)
+	if err != nil {
+		respondPlanError(c, err, "failed to get inspection plan")
+		return
+	}
+	if err := requestData.applyTo(plan); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+	plan.SetUpdater(currentUserID(c))
+
+	updated, err := services.UpdateInspectionPlan(plan)
+	if err != nil {
+		respondPlanError(c, err, "failed to update inspection plan")
+		return
+	}
+	utils.SuccessResponse(c, updated)
+}
+
+// DeleteInspectionPlan 删除点检计划
+func DeleteInspectionPlan(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid inspection plan id")
+		return
+	}
+	if err := services.DeleteInspectionPlan(id); err != nil {
+		respondPlanError(c, err, "failed to delete inspection plan")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"message": "inspection plan deleted"})
+}
+
+// GetMyTasks 获取当前用户今天的点检任务，包括已逾期、本周期待点检和已完成的任务
+func GetMyTasks(c *gin.Context) {
+	tasks, err := services.GetUserTasks(currentUserID(c), time.Now())
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get tasks")
+		return
+	}
+	utils.SuccessResponse(c, tasks)
+}
+
+// ListOverdueTasks 获取已逾期的点检任务，可按 unit_id 过滤
+func ListOverdueTasks(c *gin.Context) {
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return
+	}
+	unitID, _ := strconv.Atoi(c.Query("unit_id"))
+	tasks, err := services.GetOverdueTasks(scope, unitID, time.Now())
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get overdue tasks")
+		return
+	}
+	utils.SuccessResponse(c, tasks)
+}
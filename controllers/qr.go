@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"DLM_backend/services"
+	"DLM_backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QRVerifyRequest 校验挡粮门二维码请求结构体
+type QRVerifyRequest struct {
+	Token string `json:"token" binding:"required"` // 扫描得到的二维码内容
+}
+
+// VerifyDoorQR 校验扫描的挡粮门二维码，返回挡粮门、仓房和单位，供小程序预填点检记录
+func VerifyDoorQR(c *gin.Context) {
+	var requestData QRVerifyRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return
+	}
+
+	door, err := services.VerifyDoorQRToken(requestData.Token, scope)
+	if err != nil {
+		respondRecordError(c, err, "failed to verify qr code")
+		return
+	}
+	utils.SuccessResponse(c, door)
+}
+
+// GetDoorQRCode 获取挡粮门二维码 PNG 图片，size 为边长像素，默认 256
+func GetDoorQRCode(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid grain door id")
+		return
+	}
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "256"))
+	if size < 128 || size > 1024 {
+		size = 256
+	}
+
+	door, err := services.GetGrainDoorByID(id)
+	if err != nil {
+		respondWarehouseError(c, err, "failed to get grain door")
+		return
+	}
+	png, err := services.DoorQRPNG(door, size)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to generate qr code")
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=grain_door_%d.png", door.ID))
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// RotateDoorQR 重新生成挡粮门二维码，用于标签丢失或被复制后作废旧标签
+func RotateDoorQR(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid grain door id")
+		return
+	}
+	door, err := services.RotateDoorQR(id)
+	if err != nil {
+		respondWarehouseError(c, err, "failed to rotate qr code")
+		return
+	}
+	utils.SuccessResponse(c, door)
+}
+
+// PrintDoorQRLabels 生成可打印的挡粮门二维码标签 PDF
+// 可按 door_ids（逗号分隔）、warehouse_id 或 unit_id 选择挡粮门
+func PrintDoorQRLabels(c *gin.Context) {
+	var ids []int
+	if idsStr := c.Query("door_ids"); idsStr != "" {
+		for _, s := range strings.Split(idsStr, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				utils.ErrorResponse(c, "invalid door_ids")
+				return
+			}
+			ids = append(ids, id)
+		}
+	}
+	warehouseID, _ := strconv.Atoi(c.Query("warehouse_id"))
+	unitID, _ := strconv.Atoi(c.Query("unit_id"))
+
+	doors, err := services.GetGrainDoorsForLabels(ids, warehouseID, unitID)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get grain doors")
+		return
+	}
+	if len(doors) == 0 {
+		utils.NotFoundResponse(c, "no grain doors matched")
+		return
+	}
+
+	pdf, err := services.DoorQRLabelsPDF(doors)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to generate qr labels")
+		return
+	}
+	filename := fmt.Sprintf("grain_door_labels_%s.pdf", time.Now().Format("20060102150405"))
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"DLM_backend/models"
+	"DLM_backend/services"
+	"DLM_backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FixRectificationRequest 提交整改结果请求结构体
+type FixRectificationRequest struct {
+	AfterImages []string `json:"after_images"` // 整改后图片，至少一张
+	Note        string   `json:"note"`         // 整改说明
+}
+
+// VerifyRectificationRequest 复查整改结果请求结构体
+type VerifyRectificationRequest struct {
+	FollowUpRecordID *int `json:"follow_up_record_id"` // 同一挡粮门的后续点检记录ID，为空时自动选用最近的一条
+}
+
+// RejectRectificationRequest 复查未通过、退回重新整改请求结构体
+type RejectRectificationRequest struct {
+	Reason string `json:"reason"` // 退回原因
+}
+
+// AssignRectificationRequest 调整整改责任人和期限请求结构体
+type AssignRectificationRequest struct {
+	AssigneeID *int   `json:"assignee_id"` // 整改责任人ID
+	Deadline   string `json:"deadline"`    // 整改期限，格式 2006-01-02，当天结束前有效
+}
+
+// respondRectificationError 将整改工单服务层错误转换为响应
+func respondRectificationError(c *gin.Context, err error, fallback string) {
+	var fieldErr *services.FieldError
+	switch {
+	case errors.As(err, &fieldErr):
+		utils.FieldErrorResponse(c, fieldErr.Field, fieldErr.Message)
+	case errors.Is(err, services.ErrTicketNotFound):
+		utils.NotFoundResponse(c, err.Error())
+	case errors.Is(err, services.ErrTicketNotAssigned):
+		utils.ForbiddenResponse(c, err.Error())
+	case errors.Is(err, services.ErrInvalidTicketTransition), errors.Is(err, services.ErrUserNotFound):
+		utils.ErrorResponse(c, err.Error())
+	default:
+		utils.ServerErrorResponse(c, fallback)
+	}
+}
+
+// loadScopedTicket 根据路径参数加载整改工单，并校验在当前用户可访问的单位范围内
+// 没有整改管理权限的用户只能访问分配给自己的工单
+func loadScopedTicket(c *gin.Context) (*models.RectificationTicket, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid rectification ticket id")
+		return nil, false
+	}
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return nil, false
+	}
+	ticket, err := services.GetRectificationTicketByID(id)
+	if err == nil {
+		err = services.CheckTicketInScope(ticket, scope)
+	}
+	if err == nil && !utils.HasPermission(c, models.PermRectificationManage) {
+		if ticket.AssigneeID == nil || *ticket.AssigneeID != currentUserID(c) {
+			err = services.ErrTicketNotFound
+		}
+	}
+	if err != nil {
+		respondRectificationError(c, err, "failed to get rectification ticket")
+		return nil, false
+	}
+	return ticket, true
+}
+
+// ListRectifications 分页获取整改工单
+// 支持 status、assignee_id、record_id、grain_door_id、unit_id、overdue 过滤；没有整改管理权限时只返回分配给自己的工单
+func ListRectifications(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+
+	// 确保参数有效
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	filters := make(map[string]interface{})
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return
+	}
+	if scope != nil {
+		filters["unit_scope"] = scope
+	}
+	for _, key := range []string{"unit_id", "assignee_id", "record_id", "grain_door_id"} {
+		if value, err := strconv.Atoi(c.Query(key)); err == nil {
+			filters[key] = value
+		}
+	}
+	if !utils.HasPermission(c, models.PermRectificationManage) {
+		filters["assignee_id"] = currentUserID(c)
+	}
+	if status := c.Query("status"); status != "" {
+		filters["status"] = status
+	}
+	if overdue, err := strconv.ParseBool(c.Query("overdue")); err == nil {
+		filters["overdue"] = overdue
+	}
+
+	total, tickets, err := services.GetRectificationTickets(page, pageSize, filters)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get rectification tickets")
+		return
+	}
+
+	// 计算总页数
+	totalPages := (total + int64(pageSize) - 1) / int64(pageSize)
+
+	utils.SuccessResponse(c, gin.H{
+		"tickets": tickets,
+		"pagination": gin.H{
+			"total":      total,
+			"page":       page,
+			"pageSize":   pageSize,
+			"totalPages": totalPages,
+		},
+	})
+}
+
+// GetRectification 获取整改工单详情，包括原点检记录
+func GetRectification(c *gin.Context) {
+	ticket, ok := loadScopedTicket(c)
+	if !ok {
+		return
+	}
+	utils.SuccessResponse(c, ticket)
+}
+
+// StartRectification 开始整改
+func StartRectification(c *gin.Context) {
+	ticket, ok := loadScopedTicket(c)
+	if !ok {
+		return
+	}
+	updated, err := services.StartRectification(ticket, currentUserID(c),
+		utils.HasPermission(c, models.PermRectificationManage))
+	if err != nil {
+		respondRectificationError(c, err, "failed to start rectification")
+		return
+	}
+	utils.SuccessResponse(c, updated)
+}
+
+// FixRectification 提交整改结果和整改后图片
+func FixRectification(c *gin.Context) {
+	var requestData FixRectificationRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+	ticket, ok := loadScopedTicket(c)
+	if !ok {
+		return
+	}
+	updated, err := services.FixRectification(ticket, requestData.AfterImages, requestData.Note,
+		currentUserID(c), utils.HasPermission(c, models.PermRectificationManage))
+	if err != nil {
+		respondRectificationError(c, err, "failed to fix rectification")
+		return
+	}
+	utils.SuccessResponse(c, updated)
+}
+
+// VerifyRectification 根据同一挡粮门的后续点检记录复查整改结果并关闭工单，后续记录仍有此异常时工单退回整改中
+func VerifyRectification(c *gin.Context) {
+	var requestData VerifyRectificationRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+	ticket, ok := loadScopedTicket(c)
+	if !ok {
+		return
+	}
+	updated, err := services.VerifyRectification(ticket, requestData.FollowUpRecordID, currentUserID(c))
+	if err != nil {
+		respondRectificationError(c, err, "failed to verify rectification")
+		return
+	}
+	utils.SuccessResponse(c, updated)
+}
+
+// RejectRectification 复查未通过，将已整改的工单退回重新整改
+func RejectRectification(c *gin.Context) {
+	var requestData RejectRectificationRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+	ticket, ok := loadScopedTicket(c)
+	if !ok {
+		return
+	}
+	updated, err := services.RejectRectification(ticket, requestData.Reason, currentUserID(c))
+	if err != nil {
+		respondRectificationError(c, err, "failed to reject rectification")
+		return
+	}
+	utils.SuccessResponse(c, updated)
+}
+
+// AssignRectification 调整整改工单的责任人和整改期限
+func AssignRectification(c *gin.Context) {
+	var requestData AssignRectificationRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+	var deadline *time.Time
+	if requestData.Deadline != "" {
+		day, err := time.ParseInLocation("2006-01-02", requestData.Deadline, time.Local)
+		if err != nil {
+			utils.ErrorResponse(c, "invalid deadline, expected format 2006-01-02")
+			return
+		}
+		endOfDay := day.Add(24*time.Hour - time.Second)
+		deadline = &endOfDay
+	}
+
+	ticket, ok := loadScopedTicket(c)
+	if !ok {
+		return
+	}
+	updated, err := services.AssignRectification(ticket, requestData.AssigneeID, deadline, currentUserID(c))
+	if err != nil {
+		respondRectificationError(c, err, "failed to assign rectification")
+		return
+	}
+	utils.SuccessResponse(c, updated)
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+
+	"DLM_backend/services"
+	"DLM_backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondRecycleError 将回收站服务层错误转换为响应
+func respondRecycleError(c *gin.Context, err error, fallback string) {
+	if errors.Is(err, services.ErrRetentionNotExpired) {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+	respondRecordError(c, err, fallback)
+}
+
+// ListRecycleBin 分页获取回收站中的点检记录
+func ListRecycleBin(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+
+	// 确保参数有效
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return
+	}
+
+	total, records, err := services.GetDeletedRecords(page, pageSize, scope)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get deleted records")
+		return
+	}
+
+	// 计算总页数
+	totalPages := (total + int64(pageSize) - 1) / int64(pageSize)
+
+	utils.SuccessResponse(c, gin.H{
+		"records":         records,
+		"retention_hours": services.RecordRetention().Hours(),
+		"pagination": gin.H{
+			"total":      total,
+			"page":       page,
+			"pageSize":   pageSize,
+			"totalPages": totalPages,
+		},
+	})
+}
+
+// RestoreRecord 从回收站恢复点检记录
+func RestoreRecord(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid id")
+		return
+	}
+
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return
+	}
+
+	user := utils.CurrentUser(c)
+	if user == nil {
+		utils.UnauthorizedResponse(c, "token claims not found")
+		return
+	}
+
+	record, err := services.RestoreInspectionRecord(id, scope, user.ID)
+	if err != nil {
+		respondRecycleError(c, err, "failed to restore record")
+		return
+	}
+	utils.SuccessResponse(c, record)
+}
+
+// PurgeRecord 彻底删除回收站中超过保留时间的点检记录
+func PurgeRecord(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid id")
+		return
+	}
+
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return
+	}
+
+	if err := services.PurgeInspectionRecord(id, scope); err != nil {
+		respondRecycleError(c, err, "failed to purge record")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"message": "record purged"})
+}
+
+// PurgeExpiredRecords 彻底删除回收站中所有超过保留时间的点检记录
+func PurgeExpiredRecords(c *gin.Context) {
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return
+	}
+
+	purged, err := services.PurgeExpiredRecords(scope)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to purge records")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"purged": purged})
+}
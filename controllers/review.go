@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"strconv"
+
+	"DLM_backend/models"
+	"DLM_backend/services"
+	"DLM_backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RejectRequest 驳回点检记录请求结构体
+type RejectRequest struct {
+	Reason string `json:"reason" binding:"required"` // 驳回原因
+}
+
+// BulkApproveRequest 批量审核通过请求结构体
+type BulkApproveRequest struct {
+	IDs []int `json:"ids" binding:"required,min=1,max=500"` // 点检记录ID列表
+}
+
+// ListReviewQueue 分页获取待审核的点检记录，最早提交的排在前面
+// review_status 默认为 submitted,resubmitted，可按 unit_id、warehouse_id 过滤
+func ListReviewQueue(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+
+	// 确保参数有效
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	filters := map[string]interface{}{
+		"review_status": c.DefaultQuery("review_status", models.ReviewSubmitted+","+models.ReviewResubmitted),
+		"sort_by":       "created_at",
+		"sort_order":    "asc",
+	}
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return
+	}
+	if scope != nil {
+		filters["unit_scope"] = scope
+	}
+	if unitID, err := strconv.Atoi(c.Query("unit_id")); err == nil {
+		filters["unit_id"] = unitID
+	}
+	if warehouseID, err := strconv.Atoi(c.Query("warehouse_id")); err == nil {
+		filters["warehouse_id"] = warehouseID
+	}
+
+	total, records, err := services.GetInspectionRecordsWithFilters(page, pageSize, filters)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get review queue")
+		return
+	}
+
+	// 计算总页数
+	totalPages := (total + int64(pageSize) - 1) / int64(pageSize)
+
+	utils.SuccessResponse(c, gin.H{
+		"records": records,
+		"pagination": gin.H{
+			"total":      total,
+			"page":       page,
+			"pageSize":   pageSize,
+			"totalPages": totalPages,
+		},
+	})
+}
+
+// ApproveInspection 审核通过点检记录
+func ApproveInspection(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid id")
+		return
+	}
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return
+	}
+
+	record, err := services.ApproveInspectionRecord(id, scope, currentUserID(c))
+	if err != nil {
+		respondRecordError(c, err, "failed to approve record")
+		return
+	}
+	utils.SuccessResponse(c, record)
+}
+
+// RejectInspection 驳回点检记录，保管员修改后重新提交
+func RejectInspection(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid id")
+		return
+	}
+	var requestData RejectRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return
+	}
+
+	record, err := services.RejectInspectionRecord(id, scope, requestData.Reason, currentUserID(c))
+	if err != nil {
+		respondRecordError(c, err, "failed to reject record")
+		return
+	}
+	utils.SuccessResponse(c, record)
+}
+
+// BulkApproveInspections 批量审核通过点检记录，返回通过和未通过的记录
+func BulkApproveInspections(c *gin.Context) {
+	var requestData BulkApproveRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return
+	}
+
+	approved, failed := services.BulkApproveInspectionRecords(requestData.IDs, scope, currentUserID(c))
+	utils.SuccessResponse(c, gin.H{
+		"approved": approved,
+		"failed":   failed,
+	})
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+
+	"DLM_backend/models"
+	"DLM_backend/services"
+	"DLM_backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RevertRequest 回退点检记录请求结构体
+type RevertRequest struct {
+	Version int `json:"version" binding:"required"` // 要回退到的版本号
+}
+
+// loadScopedRecord 根据路径参数加载点检记录，并校验是否在当前用户的单位范围内
+func loadScopedRecord(c *gin.Context) (*models.InspectionRecord, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid id")
+		return nil, false
+	}
+
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return nil, false
+	}
+
+	record, err := services.GetInspectionRecordByID(id)
+	if err == nil {
+		err = services.CheckRecordInScope(record, scope)
+	}
+	if err != nil {
+		respondRecordError(c, err, "failed to get record")
+		return nil, false
+	}
+	return record, true
+}
+
+// GetInspectionHistory 获取点检记录的版本历史及每个版本的字段变更
+func GetInspectionHistory(c *gin.Context) {
+	record, ok := loadScopedRecord(c)
+	if !ok {
+		return
+	}
+
+	revisions, err := services.GetRecordRevisions(record.ID)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get record history")
+		return
+	}
+	utils.SuccessResponse(c, revisions)
+}
+
+// RevertInspection 将点检记录回退到指定版本
+func RevertInspection(c *gin.Context) {
+	record, ok := loadScopedRecord(c)
+	if !ok {
+		return
+	}
+
+	var requestData RevertRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	user := utils.CurrentUser(c)
+	if user == nil {
+		utils.UnauthorizedResponse(c, "token claims not found")
+		return
+	}
+
+	reverted, err := services.RevertInspectionRecord(record, requestData.Version, user.ID)
+	if err != nil {
+		if errors.Is(err, services.ErrRevisionNotFound) {
+			utils.NotFoundResponse(c, err.Error())
+			return
+		}
+		respondRecordError(c, err, "failed to revert record")
+		return
+	}
+	utils.SuccessResponse(c, reverted)
+}
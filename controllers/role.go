@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+
+	"DLM_backend/models"
+	"DLM_backend/services"
+	"DLM_backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateRoleRequest 新建角色请求结构体
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	DisplayName string   `json:"display_name"`
+	Permissions []string `json:"permissions"` // 权限编码列表
+}
+
+// UpdateRoleRequest 更新角色请求结构体，permissions 为空时不修改权限
+type UpdateRoleRequest struct {
+	DisplayName string   `json:"display_name"`
+	Permissions []string `json:"permissions"`
+}
+
+// respondRoleError 将角色服务层错误转换为响应
+func respondRoleError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrRoleNotFound):
+		utils.NotFoundResponse(c, err.Error())
+	case errors.Is(err, services.ErrRoleExists), errors.Is(err, services.ErrRoleInUse),
+		errors.Is(err, services.ErrUnknownPermission):
+		utils.ErrorResponse(c, err.Error())
+	default:
+		utils.ServerErrorResponse(c, fallback)
+	}
+}
+
+// ListRoles 获取所有角色及其权限
+func ListRoles(c *gin.Context) {
+	roles, err := services.GetRoles()
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get roles")
+		return
+	}
+	utils.SuccessResponse(c, roles)
+}
+
+// ListPermissions 获取所有可分配的权限
+func ListPermissions(c *gin.Context) {
+	permissions, err := services.GetPermissions()
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get permissions")
+		return
+	}
+	utils.SuccessResponse(c, permissions)
+}
+
+// CreateRole 新建角色
+func CreateRole(c *gin.Context) {
+	var requestData CreateRoleRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	role := models.Role{Name: requestData.Name, DisplayName: requestData.DisplayName}
+	role.SetCreator(currentUserID(c))
+	created, err := services.CreateRole(&role, requestData.Permissions)
+	if err != nil {
+		respondRoleError(c, err, "failed to create role")
+		return
+	}
+	utils.SuccessResponse(c, created)
+}
+
+// UpdateRole 更新角色名称和权限
+func UpdateRole(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid role id")
+		return
+	}
+
+	var requestData UpdateRoleRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	role, err := services.GetRoleByID(id)
+	if err != nil {
+		respondRoleError(c, err, "failed to get role")
+		return
+	}
+
+	// 不允许移除自己所在角色的角色管理权限，避免失去管理入口
+	if requestData.Permissions != nil {
+		if user := utils.CurrentUser(c); user != nil && user.Role == role.Name {
+			keeps := false
+			for _, code := range requestData.Permissions {
+				if code == models.PermRoleManage {
+					keeps = true
+				}
+			}
+			if !keeps {
+				utils.ErrorResponse(c, "cannot remove role:manage from your own role")
+				return
+			}
+		}
+	}
+
+	if requestData.DisplayName != "" {
+		role.DisplayName = requestData.DisplayName
+	}
+	role.SetUpdater(currentUserID(c))
+	updated, err := services.UpdateRole(role, requestData.Permissions)
+	if err != nil {
+		respondRoleError(c, err, "failed to update role")
+		return
+	}
+	utils.SuccessResponse(c, updated)
+}
+
+// DeleteRole 删除角色
+func DeleteRole(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid role id")
+		return
+	}
+	if err := services.DeleteRole(id); err != nil {
+		respondRoleError(c, err, "failed to delete role")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"message": "role deleted"})
+}
@@ -0,0 +1,323 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"DLM_backend/models"
+	"DLM_backend/services"
+	"DLM_backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/datatypes"
+)
+
+// maxSyncItems 一次离线同步最多提交的记录数
+const maxSyncItems = 100
+
+// maxSyncFiles 一次离线同步最多上传的图片文件数
+const maxSyncFiles = 50
+
+// maxSyncPayload 离线同步请求中图片文件以外内容的大小上限
+const maxSyncPayload = 8 << 20
+
+// errTooManySyncFiles 离线同步请求上传的图片文件过多
+var errTooManySyncFiles = fmt.Errorf("too many files in one sync request, at most %d", maxSyncFiles)
+
+// syncFilePrefix 图片列表中以此前缀引用同一请求中上传的图片文件，例如 "file:img1" 对应表单字段 img1
+const syncFilePrefix = "file:"
+
+// 离线同步单条记录的处理结果
+const (
+	SyncStatusCreated   = "created"   // 已新建
+	SyncStatusUpdated   = "updated"   // 已修改
+	SyncStatusDuplicate = "duplicate" // 幂等键已处理过，未重复保存
+	SyncStatusConflict  = "conflict"  // 服务端记录已被修改或删除，未保存
+	SyncStatusInvalid   = "invalid"   // 内容校验失败或无权操作，未保存
+	SyncStatusFailed    = "failed"    // 服务端错误，可稍后重试
+)
+
+// SyncRequest 离线批量同步请求结构体
+// 可直接提交 JSON，也可用 multipart 表单提交：payload 字段为 JSON，其余字段为图片文件
+type SyncRequest struct {
+	Items []SyncItem `json:"items" binding:"required,min=1"` // 离线采集的点检记录
+}
+
+// SyncItem 离线采集的一条点检记录
+type SyncItem struct {
+	IdempotencyKey string          `json:"idempotency_key"` // 客户端生成的幂等键，重试时保持不变
+	RecordID       *int            `json:"record_id"`       // 修改已有记录时提供记录ID，为空表示新建
+	BaseVersion    *int            `json:"base_version"`    // 修改已有记录时必填，客户端离线前看到的记录版本号
+	Record         json.RawMessage `json:"record"`          // 点检记录内容，字段与新增点检记录接口一致
+}
+
+// SyncItemResult 离线同步单条记录的处理结果
+type SyncItemResult struct {
+	Index          int                      `json:"index"`                   // 在请求中的序号，从0开始
+	IdempotencyKey string                   `json:"idempotency_key"`         // 幂等键
+	Status         string                   `json:"status"`                  // 处理结果
+	RecordID       int                      `json:"record_id,omitempty"`     // 点检记录ID
+	Field          string                   `json:"field,omitempty"`         // 校验失败的字段
+	Code           string                   `json:"code,omitempty"`          // 图片未通过校验时的错误码
+	Error          string                   `json:"error,omitempty"`         // 失败原因
+	ServerRecord   *models.InspectionRecord `json:"server_record,omitempty"` // 冲突时服务端的当前记录，供客户端合并
+}
+
+// limitSyncBody 限制离线同步请求体的大小，按每个文件的图片大小限制和文件数上限计算
+func limitSyncBody(c *gin.Context) {
+	if maxBytes := services.GetImagePolicy().MaxBytes; maxBytes > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes*maxSyncFiles+maxSyncPayload)
+	}
+}
+
+// bindSyncRequest 读取 JSON 或 multipart 格式的离线同步请求
+func bindSyncRequest(c *gin.Context, requestData *SyncRequest) error {
+	limitSyncBody(c)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		form, err := c.MultipartForm()
+		if err != nil {
+			return err
+		}
+		files := 0
+		for _, headers := range form.File {
+			files += len(headers)
+		}
+		if files > maxSyncFiles {
+			return errTooManySyncFiles
+		}
+		payload := c.PostForm("payload")
+		if payload == "" {
+			return errors.New("missing payload")
+		}
+		if err := json.Unmarshal([]byte(payload), requestData); err != nil {
+			return err
+		}
+		return binding.Validator.ValidateStruct(requestData)
+	}
+	return c.ShouldBindJSON(requestData)
+}
+
+// resolveSyncImages 将记录和各点检项图片列表中引用的上传文件保存到服务器，并替换为访问路径，返回保存的附件
+// 任一图片未通过校验时删除本条记录已保存的图片
+func resolveSyncImages(c *gin.Context, requestData *InspectionRequest) ([]*models.Attachment, error) {
+	var saved []*models.Attachment
+	if len(requestData.Images) > 0 {
+		var images []string
+		if err := json.Unmarshal(requestData.Images, &images); err == nil {
+			changed, err := resolveSyncFiles(c, images, &saved)
+			if err != nil {
+				removeUploadedImages(saved)
+				return nil, err
+			}
+			if changed {
+				imagesJSON, _ := json.Marshal(images)
+				requestData.Images = datatypes.JSON(imagesJSON)
+			}
+		}
+	}
+	for _, answer := range requestData.Answers {
+		if _, err := resolveSyncFiles(c, answer.Images, &saved); err != nil {
+			removeUploadedImages(saved)
+			return nil, err
+		}
+	}
+	return saved, nil
+}
+
+// resolveSyncFiles 原地替换图片列表中的上传文件引用，保存的附件追加到 saved，返回是否有替换
+func resolveSyncFiles(c *gin.Context, images []string, saved *[]*models.Attachment) (bool, error) {
+	changed := false
+	for i, image := range images {
+		if !strings.HasPrefix(image, syncFilePrefix) {
+			continue
+		}
+		file, err := c.FormFile(strings.TrimPrefix(image, syncFilePrefix))
+		if err != nil {
+			return false, &services.FieldError{Field: "images", Message: "referenced file " + image + " was not uploaded"}
+		}
+		attachment := &models.Attachment{}
+		if err := saveUploadedImage(c, file, attachment); err != nil {
+			return false, err
+		}
+		*saved = append(*saved, attachment)
+		images[i] = attachment.URL
+		changed = true
+	}
+	return changed, nil
+}
+
+// setSyncError 按错误类型写入单条记录的处理结果
+func setSyncError(result *SyncItemResult, err error) {
+	var fieldErr *services.FieldError
+	var imageErr *services.ImageRejectedError
+	switch {
+	case errors.As(err, &imageErr):
+		result.Status = SyncStatusInvalid
+		result.Field = "images"
+		result.Code = imageErr.Code
+		result.Error = imageErr.Error()
+	case errors.As(err, &fieldErr):
+		result.Status = SyncStatusInvalid
+		result.Field = fieldErr.Field
+		result.Error = fieldErr.Error()
+	case errors.Is(err, services.ErrSyncConflict):
+		result.Status = SyncStatusConflict
+		result.Error = err.Error()
+	case errors.Is(err, services.ErrRecordNotFound), errors.Is(err, services.ErrRecordNotOwned),
+		errors.Is(err, services.ErrEditWindowExpired), errors.Is(err, services.ErrUnitNotAccessible),
+		errors.Is(err, services.ErrUnitNotFound), errors.Is(err, services.ErrGrainDoorNotFound),
+		errors.Is(err, services.ErrInvalidQRToken), errors.Is(err, services.ErrQRTokenMismatch):
+		result.Status = SyncStatusInvalid
+		result.Error = err.Error()
+	default:
+		result.Status = SyncStatusFailed
+		result.Error = "failed to save record"
+	}
+}
+
+// SyncInspections 批量同步离线采集的点检记录
+// 每条记录独立处理并返回结果；相同幂等键重复提交不会重复保存；修改的记录在离线期间被他人修改时返回冲突
+func SyncInspections(c *gin.Context) {
+	var requestData SyncRequest
+	if err := bindSyncRequest(c, &requestData); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			utils.CodedErrorResponse(c, http.StatusRequestEntityTooLarge, services.ImageErrTooLarge,
+				fmt.Sprintf("sync request exceeds the maximum size of %d bytes", maxBytesErr.Limit))
+			return
+		}
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+	if len(requestData.Items) > maxSyncItems {
+		utils.ErrorResponse(c, "too many items in one sync request")
+		return
+	}
+
+	user := utils.CurrentUser(c)
+	if user == nil {
+		utils.UnauthorizedResponse(c, "token claims not found")
+		return
+	}
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return
+	}
+	canUpdateOwn := utils.HasPermission(c, models.PermInspectionUpdateOwn)
+	canUpdateAny := utils.HasPermission(c, models.PermInspectionUpdateAny)
+
+	results := make([]SyncItemResult, 0, len(requestData.Items))
+	summary := map[string]int{}
+	seen := make(map[string]bool, len(requestData.Items))
+	for i, item := range requestData.Items {
+		result := SyncItemResult{Index: i, IdempotencyKey: item.IdempotencyKey}
+		syncItem(c, &item, &result, seen, user.ID, scope, canUpdateOwn || canUpdateAny, canUpdateAny)
+		summary[result.Status]++
+		results = append(results, result)
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"results": results,
+		"summary": summary,
+	})
+}
+
+// syncItem 处理一条离线记录并写入处理结果
+func syncItem(c *gin.Context, item *SyncItem, result *SyncItemResult, seen map[string]bool,
+	userID int, scope []int, canUpdate, canUpdateAny bool) {
+	key := strings.TrimSpace(item.IdempotencyKey)
+	if key == "" || len(key) > 128 {
+		setSyncError(result, &services.FieldError{Field: "idempotency_key", Message: "required and at most 128 characters"})
+		return
+	}
+	if seen[key] {
+		setSyncError(result, &services.FieldError{Field: "idempotency_key", Message: "duplicated in this request"})
+		return
+	}
+	seen[key] = true
+
+	// 已处理过的幂等键直接返回原结果，不再保存图片和记录
+	if receipt, err := services.FindSyncReceipt(userID, key); err != nil {
+		setSyncError(result, err)
+		return
+	} else if receipt != nil {
+		result.Status = SyncStatusDuplicate
+		result.RecordID = receipt.RecordID
+		return
+	}
+
+	var requestData InspectionRequest
+	if err := json.Unmarshal(item.Record, &requestData); err != nil {
+		setSyncError(result, &services.FieldError{Field: "record", Message: "invalid record: " + err.Error()})
+		return
+	}
+	if err := binding.Validator.ValidateStruct(&requestData); err != nil {
+		setSyncError(result, &services.FieldError{Field: "record", Message: err.Error()})
+		return
+	}
+
+	var record *models.InspectionRecord
+	if item.RecordID != nil {
+		if !canUpdate {
+			setSyncError(result, services.ErrRecordNotOwned)
+			return
+		}
+		if item.BaseVersion == nil {
+			setSyncError(result, &services.FieldError{Field: "base_version", Message: "required when updating a record"})
+			return
+		}
+		existing, err := services.GetRecordForSync(*item.RecordID, scope, userID, canUpdateAny)
+		if err != nil {
+			if errors.Is(err, services.ErrSyncConflict) {
+				result.ServerRecord = existing
+			}
+			setSyncError(result, err)
+			return
+		}
+		record = existing
+	} else {
+		record = &models.InspectionRecord{UserID: userID}
+	}
+
+	uploaded, err := resolveSyncImages(c, &requestData)
+	if err != nil {
+		setSyncError(result, err)
+		return
+	}
+	requestData.applyTo(record)
+	if err := requestData.resolveUnit(record, scope); err != nil {
+		// 记录未保存，本条记录上传的图片不会被引用
+		removeUploadedImages(uploaded)
+		setSyncError(result, err)
+		return
+	}
+
+	var saved *models.InspectionRecord
+	if item.RecordID != nil {
+		saved, err = services.SyncUpdateRecord(record, *item.BaseVersion, key, userID)
+		result.Status = SyncStatusUpdated
+	} else {
+		saved, err = services.SyncCreateRecord(record, key)
+		result.Status = SyncStatusCreated
+	}
+	if err != nil {
+		removeUploadedImages(uploaded)
+		// 并发重试时另一个请求可能已处理了相同的幂等键
+		if receipt, findErr := services.FindSyncReceipt(userID, key); findErr == nil && receipt != nil {
+			result.Status = SyncStatusDuplicate
+			result.RecordID = receipt.RecordID
+			return
+		}
+		if errors.Is(err, services.ErrSyncConflict) {
+			result.ServerRecord = saved
+		}
+		setSyncError(result, err)
+		return
+	}
+	result.RecordID = saved.ID
+}
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+
+	"DLM_backend/models"
+	"DLM_backend/services"
+	"DLM_backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnitRequest 新建/更新单位请求结构体
+type UnitRequest struct {
+	Name   string `json:"name" binding:"required"`
+	Code   string `json:"code"`
+	Remark string `json:"remark"`
+}
+
+// UserUnitsRequest 设置用户所属单位请求结构体
+type UserUnitsRequest struct {
+	UnitIDs []int `json:"unit_ids"`
+}
+
+// unitScope 返回当前用户可访问的单位ID列表
+// 拥有跨单位权限（总部）时返回 nil，表示不限制单位
+func unitScope(c *gin.Context) ([]int, error) {
+	if utils.HasPermission(c, models.PermUnitAll) {
+		return nil, nil
+	}
+	user := utils.CurrentUser(c)
+	if user == nil {
+		return []int{}, nil
+	}
+	return services.GetUserUnitIDs(user.ID)
+}
+
+// respondUnitError 将单位服务层错误转换为响应
+func respondUnitError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrUnitNotFound):
+		utils.NotFoundResponse(c, err.Error())
+	case errors.Is(err, services.ErrUnitExists), errors.Is(err, services.ErrUnitInUse):
+		utils.ErrorResponse(c, err.Error())
+	case errors.Is(err, services.ErrUnitNotAccessible):
+		utils.ForbiddenResponse(c, err.Error())
+	default:
+		utils.ServerErrorResponse(c, fallback)
+	}
+}
+
+// GetMyUnits 获取当前用户可访问的单位，供小程序选择单位使用
+func GetMyUnits(c *gin.Context) {
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get units")
+		return
+	}
+	units, err := services.GetUnits(scope)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get units")
+		return
+	}
+	utils.SuccessResponse(c, units)
+}
+
+// ListUnits 获取所有单位
+func ListUnits(c *gin.Context) {
+	units, err := services.GetUnits(nil)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get units")
+		return
+	}
+	utils.SuccessResponse(c, units)
+}
+
+// CreateUnit 新建单位
+func CreateUnit(c *gin.Context) {
+	var requestData UnitRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	unit := models.Unit{Name: requestData.Name, Code: requestData.Code, Remark: requestData.Remark}
+	unit.SetCreator(currentUserID(c))
+	created, err := services.CreateUnit(&unit)
+	if err != nil {
+		respondUnitError(c, err, "failed to create unit")
+		return
+	}
+	utils.SuccessResponse(c, created)
+}
+
+// UpdateUnit 更新单位信息
+func UpdateUnit(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid unit id")
+		return
+	}
+
+	var requestData UnitRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	unit, err := services.GetUnitByID(id)
+	if err != nil {
+		respondUnitError(c, err, "failed to get unit")
+		return
+	}
+	unit.Name = requestData.Name
+	unit.Code = requestData.Code
+	unit.Remark = requestData.Remark
+	unit.SetUpdater(currentUserID(c))
+
+	updated, err := services.UpdateUnit(unit)
+	if err != nil {
+		respondUnitError(c, err, "failed to update unit")
+		return
+	}
+	utils.SuccessResponse(c, updated)
+}
+
+// DeleteUnit 删除单位
+func DeleteUnit(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid unit id")
+		return
+	}
+	if err := services.DeleteUnit(id); err != nil {
+		respondUnitError(c, err, "failed to delete unit")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"message": "unit deleted"})
+}
+
+// SetUserUnits 设置用户所属的单位
+func SetUserUnits(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	var requestData UserUnitsRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	user, err := services.SetUserUnits(id, requestData.UnitIDs)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			utils.NotFoundResponse(c, err.Error())
+			return
+		}
+		respondUnitError(c, err, "failed to set user units")
+		return
+	}
+	utils.SuccessResponse(c, user)
+}
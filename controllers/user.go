@@ -0,0 +1,269 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+
+	"DLM_backend/models"
+	"DLM_backend/services"
+	"DLM_backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateUserRequest 管理员新建用户请求结构体
+type CreateUserRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Role     string `json:"role" binding:"required"` // 角色名称，需在 roles 表中存在
+	Name     string `json:"name"`
+	Phone    string `json:"phone"`
+}
+
+// UpdateUserRequest 管理员更新用户请求结构体，只更新提供的字段
+type UpdateUserRequest struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Name     string `json:"name"`
+	Phone    string `json:"phone"`
+}
+
+// UserStatusRequest 停用/启用用户请求结构体
+type UserStatusRequest struct {
+	Disabled *bool `json:"disabled" binding:"required"`
+}
+
+// ResetPasswordRequest 管理员重置密码请求结构体
+type ResetPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// parseUserID 解析路径中的用户ID
+func parseUserID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid user id")
+		return 0, false
+	}
+	return id, true
+}
+
+// isCurrentUser 判断目标用户是否为当前登录用户
+func isCurrentUser(c *gin.Context, user *models.User) bool {
+	current := utils.CurrentUser(c)
+	return current != nil && current.ID == user.ID
+}
+
+// respondUserError 将用户服务层错误转换为响应
+func respondUserError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		utils.NotFoundResponse(c, err.Error())
+	case errors.Is(err, services.ErrUserExists), errors.Is(err, services.ErrUserHasRecords),
+		errors.Is(err, utils.ErrWeakPassword):
+		utils.ErrorResponse(c, err.Error())
+	default:
+		utils.ServerErrorResponse(c, fallback)
+	}
+}
+
+// ListUsers 分页查询用户列表，支持按姓名/手机号搜索
+func ListUsers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+
+	// 确保参数有效
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	total, users, err := services.GetUsersWithFilters(page, pageSize, c.Query("keyword"), c.Query("role"))
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get users")
+		return
+	}
+
+	// 计算总页数
+	totalPages := (total + int64(pageSize) - 1) / int64(pageSize)
+
+	utils.SuccessResponse(c, gin.H{
+		"users": users,
+		"pagination": gin.H{
+			"total":      total,
+			"page":       page,
+			"pageSize":   pageSize,
+			"totalPages": totalPages,
+		},
+	})
+}
+
+// GetUser 获取单个用户信息
+func GetUser(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+	user, err := services.GetUserByID(id)
+	if err != nil {
+		respondUserError(c, err, "failed to get user")
+		return
+	}
+	utils.SuccessResponse(c, user)
+}
+
+// CreateUser 管理员新建保管员或管理员账号
+func CreateUser(c *gin.Context) {
+	var requestData CreateUserRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+	if !services.RoleExists(requestData.Role) {
+		utils.ErrorResponse(c, "invalid role")
+		return
+	}
+
+	user := models.User{
+		Username: requestData.Username,
+		Role:     requestData.Role,
+		Name:     requestData.Name,
+		Phone:    requestData.Phone,
+	}
+	user.SetCreator(currentUserID(c))
+	created, err := services.CreateUser(&user, requestData.Password)
+	if err != nil {
+		respondUserError(c, err, "failed to create user")
+		return
+	}
+	utils.SuccessResponse(c, created)
+}
+
+// UpdateUser 管理员更新用户信息及角色
+func UpdateUser(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	var requestData UpdateUserRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+	if requestData.Role != "" && !services.RoleExists(requestData.Role) {
+		utils.ErrorResponse(c, "invalid role")
+		return
+	}
+
+	user, err := services.GetUserByID(id)
+	if err != nil {
+		respondUserError(c, err, "failed to get user")
+		return
+	}
+
+	// 不允许管理员修改自己的角色，避免误操作后失去管理权限
+	if requestData.Role != "" && requestData.Role != user.Role && isCurrentUser(c, user) {
+		utils.ErrorResponse(c, "cannot change your own role")
+		return
+	}
+
+	// 只更新提供的字段
+	if requestData.Username != "" {
+		user.Username = requestData.Username
+	}
+	if requestData.Role != "" {
+		user.Role = requestData.Role
+	}
+	if requestData.Name != "" {
+		user.Name = requestData.Name
+	}
+	if requestData.Phone != "" {
+		user.Phone = requestData.Phone
+	}
+
+	user.SetUpdater(currentUserID(c))
+	updated, err := services.UpdateUser(user)
+	if err != nil {
+		respondUserError(c, err, "failed to update user")
+		return
+	}
+	utils.SuccessResponse(c, updated)
+}
+
+// UpdateUserStatus 停用或启用用户账号
+func UpdateUserStatus(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	var requestData UserStatusRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	user, err := services.GetUserByID(id)
+	if err != nil {
+		respondUserError(c, err, "failed to get user")
+		return
+	}
+	if *requestData.Disabled && isCurrentUser(c, user) {
+		utils.ErrorResponse(c, "cannot disable your own account")
+		return
+	}
+
+	updated, err := services.SetUserDisabled(id, *requestData.Disabled, currentUserID(c))
+	if err != nil {
+		respondUserError(c, err, "failed to update user status")
+		return
+	}
+	utils.SuccessResponse(c, updated)
+}
+
+// ResetUserPassword 管理员重置用户密码
+func ResetUserPassword(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	var requestData ResetPasswordRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	if err := services.ResetUserPassword(id, requestData.Password, currentUserID(c)); err != nil {
+		respondUserError(c, err, "failed to reset password")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"message": "password reset"})
+}
+
+// DeleteUser 删除用户账号
+func DeleteUser(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	user, err := services.GetUserByID(id)
+	if err != nil {
+		respondUserError(c, err, "failed to get user")
+		return
+	}
+	if isCurrentUser(c, user) {
+		utils.ErrorResponse(c, "cannot delete your own account")
+		return
+	}
+
+	if err := services.DeleteUser(id); err != nil {
+		respondUserError(c, err, "failed to delete user")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"message": "user deleted"})
+}
@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"DLM_backend/models"
+	"DLM_backend/services"
+	"DLM_backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WarehouseRequest 新建/更新仓房请求结构体
+type WarehouseRequest struct {
+	UnitID      int     `json:"unit_id" binding:"required"` // 所属单位ID
+	Number      string  `json:"number" binding:"required"`  // 仓号
+	Name        string  `json:"name"`                       // 仓房名称，为空时按仓号生成
+	Capacity    float64 `json:"capacity"`                   // 设计仓容（吨）
+	CaretakerID *int    `json:"caretaker_id"`               // 保管责任人ID
+	Remark      string  `json:"remark"`                     // 备注
+}
+
+// GrainDoorRequest 新建/更新挡粮门请求结构体
+type GrainDoorRequest struct {
+	Position    string `json:"position" binding:"required"` // 门位置
+	DoorType    string `json:"door_type"`                   // 挡粮门类型
+	InstallDate string `json:"install_date"`                // 安装日期，格式 2006-01-02
+	Remark      string `json:"remark"`                      // 备注
+}
+
+// applyTo 将请求内容写入挡粮门模型
+func (r *GrainDoorRequest) applyTo(door *models.GrainDoor) error {
+	door.Position = r.Position
+	door.DoorType = r.DoorType
+	door.Remark = r.Remark
+	door.InstallDate = nil
+	if r.InstallDate != "" {
+		installDate, err := time.ParseInLocation("2006-01-02", r.InstallDate, time.Local)
+		if err != nil {
+			return errors.New("invalid install_date, expected format 2006-01-02")
+		}
+		door.InstallDate = &installDate
+	}
+	return nil
+}
+
+// respondWarehouseError 将仓房和挡粮门服务层错误转换为响应
+func respondWarehouseError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrWarehouseNotFound), errors.Is(err, services.ErrGrainDoorNotFound):
+		utils.NotFoundResponse(c, err.Error())
+	case errors.Is(err, services.ErrWarehouseExists), errors.Is(err, services.ErrWarehouseInUse),
+		errors.Is(err, services.ErrGrainDoorExists), errors.Is(err, services.ErrGrainDoorInUse),
+		errors.Is(err, services.ErrInvalidMasterData), errors.Is(err, services.ErrUnitNotFound),
+		errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrPlanReferenced),
+		errors.Is(err, services.ErrWarehouseUnitLocked):
+		utils.ErrorResponse(c, err.Error())
+	case errors.Is(err, services.ErrUnitNotAccessible):
+		utils.ForbiddenResponse(c, err.Error())
+	default:
+		utils.ServerErrorResponse(c, fallback)
+	}
+}
+
+// GetMyWarehouses 获取当前用户可访问单位的仓房和挡粮门，供小程序选择挡粮门使用
+func GetMyWarehouses(c *gin.Context) {
+	scope, err := unitScope(c)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get user units")
+		return
+	}
+	unitID, _ := strconv.Atoi(c.Query("unit_id"))
+	warehouses, err := services.GetWarehouses(scope, unitID)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get warehouses")
+		return
+	}
+	utils.SuccessResponse(c, warehouses)
+}
+
+// ListWarehouses 获取所有仓房和挡粮门
+func ListWarehouses(c *gin.Context) {
+	unitID, _ := strconv.Atoi(c.Query("unit_id"))
+	warehouses, err := services.GetWarehouses(nil, unitID)
+	if err != nil {
+		utils.ServerErrorResponse(c, "failed to get warehouses")
+		return
+	}
+	utils.SuccessResponse(c, warehouses)
+}
+
+// CreateWarehouse 新建仓房
+func CreateWarehouse(c *gin.Context) {
+	var requestData WarehouseRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	warehouse := models.Warehouse{
+		UnitID:      requestData.UnitID,
+		Number:      requestData.Number,
+		Name:        requestData.Name,
+		Capacity:    requestData.Capacity,
+		CaretakerID: requestData.CaretakerID,
+		Remark:      requestData.Remark,
+	}
+	warehouse.SetCreator(currentUserID(c))
+	created, err := services.CreateWarehouse(&warehouse)
+	if err != nil {
+		respondWarehouseError(c, err, "failed to create warehouse")
+		return
+	}
+	utils.SuccessResponse(c, created)
+}
+
+// UpdateWarehouse 更新仓房信息
+func UpdateWarehouse(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid warehouse id")
+		return
+	}
+
+	var requestData WarehouseRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	warehouse, err := services.GetWarehouseByID(id)
+	if err != nil {
+		respondWarehouseError(c, err, "failed to get warehouse")
+		return
+	}
+	warehouse.UnitID = requestData.UnitID
+	warehouse.Number = requestData.Number
+	if requestData.Name != "" {
+		warehouse.Name = requestData.Name
+	}
+	warehouse.Capacity = requestData.Capacity
+	warehouse.CaretakerID = requestData.CaretakerID
+	warehouse.Remark = requestData.Remark
+	warehouse.SetUpdater(currentUserID(c))
+
+	updated, err := services.UpdateWarehouse(warehouse)
+	if err != nil {
+		respondWarehouseError(c, err, "failed to update warehouse")
+		return
+	}
+	utils.SuccessResponse(c, updated)
+}
+
+// DeleteWarehouse 删除仓房
+func DeleteWarehouse(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid warehouse id")
+		return
+	}
+	if err := services.DeleteWarehouse(id); err != nil {
+		respondWarehouseError(c, err, "failed to delete warehouse")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"message": "warehouse deleted"})
+}
+
+// CreateGrainDoor 在仓房下新建挡粮门
+func CreateGrainDoor(c *gin.Context) {
+	warehouseID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid warehouse id")
+		return
+	}
+
+	var requestData GrainDoorRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	door := models.GrainDoor{WarehouseID: warehouseID}
+	if err := requestData.applyTo(&door); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+	door.SetCreator(currentUserID(c))
+	created, err := services.CreateGrainDoor(&door)
+	if err != nil {
+		respondWarehouseError(c, err, "failed to create grain door")
+		return
+	}
+	utils.SuccessResponse(c, created)
+}
+
+// UpdateGrainDoor 更新挡粮门信息
+func UpdateGrainDoor(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid grain door id")
+		return
+	}
+
+	var requestData GrainDoorRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	door, err := services.GetGrainDoorByID(id)
+	if err != nil {
+		respondWarehouseError(c, err, "failed to get grain door")
+		return
+	}
+	if err := requestData.applyTo(door); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+	door.SetUpdater(currentUserID(c))
+
+	updated, err := services.UpdateGrainDoor(door)
+	if err != nil {
+		respondWarehouseError(c, err, "failed to update grain door")
+		return
+	}
+	utils.SuccessResponse(c, updated)
+}
+
+// DeleteGrainDoor 删除挡粮门
+func DeleteGrainDoor(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, "invalid grain door id")
+		return
+	}
+	if err := services.DeleteGrainDoor(id); err != nil {
+		respondWarehouseError(c, err, "failed to delete grain door")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"message": "grain door deleted"})
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"errors"
+
+	"DLM_backend/services"
+	"DLM_backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WechatCodeRequest 小程序 wx.login 获取的 code
+type WechatCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// WechatLogin 使用 wx.login 的 code 一键登录已绑定的账号
+func WechatLogin(c *gin.Context) {
+	var requestData WechatCodeRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	tokens, user, err := services.WechatLogin(requestData.Code, clientInfo(c))
+	if respondLoginLocked(c, err) {
+		return
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWechatNotBound), errors.Is(err, services.ErrAccountDisabled):
+			utils.UnauthorizedResponse(c, err.Error())
+		case errors.Is(err, services.ErrWechatNotConfigured):
+			utils.ServerErrorResponse(c, err.Error())
+		default:
+			utils.ErrorResponse(c, "wechat login failed: "+err.Error())
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"user": gin.H{
+			"username": user.Username,
+			"role":     user.Role,
+		},
+	})
+}
+
+// BindWechat 将当前微信绑定到已登录的账号，之后可直接使用微信登录
+func BindWechat(c *gin.Context) {
+	user := utils.CurrentUser(c)
+	if user == nil {
+		utils.UnauthorizedResponse(c, "token claims not found")
+		return
+	}
+
+	var requestData WechatCodeRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		utils.ErrorResponse(c, err.Error())
+		return
+	}
+
+	if err := services.BindWechat(user, requestData.Code); err != nil {
+		switch {
+		case errors.Is(err, services.ErrWechatAlreadyBound):
+			utils.ErrorResponse(c, err.Error())
+		case errors.Is(err, services.ErrWechatNotConfigured):
+			utils.ServerErrorResponse(c, err.Error())
+		default:
+			utils.ErrorResponse(c, "wechat bind failed: "+err.Error())
+		}
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"message": "wechat bound"})
+}
+
+// UnbindWechat 解除当前账号的微信绑定
+func UnbindWechat(c *gin.Context) {
+	user := utils.CurrentUser(c)
+	if user == nil {
+		utils.UnauthorizedResponse(c, "token claims not found")
+		return
+	}
+
+	if err := services.UnbindWechat(user); err != nil {
+		utils.ServerErrorResponse(c, "failed to unbind wechat")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"message": "wechat unbound"})
+}
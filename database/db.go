@@ -32,10 +32,79 @@ func InitDB(cfg *config.Config) *gorm.DB {
 	}
 
 	// 自动迁移模型
-	if err := db.AutoMigrate(&models.User{}, &models.InspectionRecord{}); err != nil {
+	if err := db.AutoMigrate(
+		&models.User{},
+		&models.InspectionRecord{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.Role{},
+		&models.Permission{},
+		&models.Unit{},
+		&models.LoginAttempt{},
+		&models.LoginLock{},
+		&models.InspectionRevision{},
+		&models.Warehouse{},
+		&models.GrainDoor{},
+		&models.InspectionPlan{},
+		&models.RectificationTicket{},
+		&models.InspectionDraft{},
+		&models.SyncReceipt{},
+		&models.ChecklistTemplate{},
+		&models.Attachment{},
+	); err != nil {
 		log.Fatalf("failed to migrate models: %v", err)
 	}
 
+	// 写入内置角色和权限
+	if err := seedRolesAndPermissions(db); err != nil {
+		log.Fatalf("failed to seed roles and permissions: %v", err)
+	}
+
+	// 写入内置默认点检模板
+	if err := seedChecklistTemplate(db); err != nil {
+		log.Fatalf("failed to seed checklist template: %v", err)
+	}
+
+	// 历史点检记录按单位名称补齐单位关联
+	if err := backfillRecordUnits(db); err != nil {
+		log.Fatalf("failed to backfill record units: %v", err)
+	}
+
+	// 历史点检记录按仓号和门位置匹配挡粮门主数据
+	if err := backfillRecordDoors(db); err != nil {
+		log.Fatalf("failed to backfill record grain doors: %v", err)
+	}
+
+	// 历史点检记录以提交人作为创建人
+	if err := backfillRecordCreators(db); err != nil {
+		log.Fatalf("failed to backfill record creators: %v", err)
+	}
+
+	// 点检记录不再保存二维码原文，改为保存摘要
+	if err := migrateRecordQRTokens(db); err != nil {
+		log.Fatalf("failed to migrate record qr tokens: %v", err)
+	}
+
+	// 历史点检记录进入待审核状态
+	if err := backfillRecordReviews(db); err != nil {
+		log.Fatalf("failed to backfill record review status: %v", err)
+	}
+
+	// 历史点检记录使用默认点检模板
+	if err := backfillRecordTemplates(db); err != nil {
+		log.Fatalf("failed to backfill record checklist templates: %v", err)
+	}
+
+	// 历史点检记录的图片登记为附件
+	if err := backfillRecordAttachments(db, config.ImageUploadDir); err != nil {
+		log.Fatalf("failed to backfill record attachments: %v", err)
+	}
+
+	// 没有缩略版本的附件使用原图作为缩略图和中图
+	if err := backfillAttachmentVariants(db); err != nil {
+		log.Fatalf("failed to backfill attachment variants: %v", err)
+	}
+
 	// 导出数据库实例
 	DB = db
 	return db
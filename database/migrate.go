@@ -0,0 +1,259 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"DLM_backend/models"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// backfillRecordUnits 为没有单位ID的历史点检记录按单位名称建立单位并补齐关联，
+// 同时将记录的提交人加入对应单位，保证迁移后保管员仍能看到自己提交的记录
+func backfillRecordUnits(db *gorm.DB) error {
+	var records []models.InspectionRecord
+	if err := db.Select("id", "user_id", "unit").Where("unit_id IS NULL").Find(&records).Error; err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		units := make(map[string]int)
+		for _, record := range records {
+			name := strings.TrimSpace(record.Unit)
+			if name == "" {
+				name = "未分配单位"
+			}
+			unitID, ok := units[name]
+			if !ok {
+				unit := models.Unit{Name: name}
+				if err := tx.Where(models.Unit{Name: name}).FirstOrCreate(&unit).Error; err != nil {
+					return err
+				}
+				unitID = unit.ID
+				units[name] = unitID
+			}
+
+			if err := tx.Model(&models.InspectionRecord{}).Where("id = ?", record.ID).
+				Update("unit_id", unitID).Error; err != nil {
+				return err
+			}
+
+			if record.UserID != 0 {
+				link := map[string]interface{}{"user_id": record.UserID, "unit_id": unitID}
+				if err := tx.Table("user_units").Clauses(clause.OnConflict{DoNothing: true}).
+					Create(link).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// backfillRecordCreators 为没有创建人的历史点检记录补齐创建人，取记录的提交人
+// 历史记录没有提交时间，created_at 保持为空，不用检查时间代替，避免掩盖补录的记录
+func backfillRecordCreators(db *gorm.DB) error {
+	return db.Model(&models.InspectionRecord{}).Unscoped().
+		Where("created_by IS NULL AND user_id IS NOT NULL").
+		UpdateColumn("created_by", gorm.Expr("user_id")).Error
+}
+
+// backfillRecordReviews 审核流程上线前的历史点检记录没有审核状态，标记为已提交待审核
+func backfillRecordReviews(db *gorm.DB) error {
+	return db.Model(&models.InspectionRecord{}).Unscoped().
+		Where("review_status IS NULL OR review_status = ''").
+		UpdateColumn("review_status", models.ReviewSubmitted).Error
+}
+
+// backfillRecordDoors 为没有挡粮门ID的历史点检记录匹配挡粮门主数据
+// 仓号和门位置按规范化后的值模糊匹配（"1号仓"、"1仓"、"01" 视为同一仓房），
+// 主数据中不存在时自动建立，仓号或门位置无法识别的记录保持为空
+func backfillRecordDoors(db *gorm.DB) error {
+	var records []models.InspectionRecord
+	if err := db.Unscoped().Select("id", "unit_id", "warehouse_number", "grain_door_position").
+		Where("grain_door_id IS NULL AND unit_id IS NOT NULL").Find(&records).Error; err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		warehouses := make(map[string]int)
+		doors := make(map[string]int)
+		for _, record := range records {
+			number := models.NormalizeWarehouseNumber(record.WarehouseNumber)
+			position := models.NormalizeDoorPosition(record.GrainDoorPosition)
+			if number == "" || position == "" {
+				continue
+			}
+
+			warehouseKey := fmt.Sprintf("%d/%s", *record.UnitID, number)
+			warehouseID, ok := warehouses[warehouseKey]
+			if !ok {
+				warehouse := models.Warehouse{UnitID: *record.UnitID, Number: number}
+				if err := tx.Where(models.Warehouse{UnitID: *record.UnitID, Number: number}).
+					Attrs(models.Warehouse{Name: models.DefaultWarehouseName(number)}).
+					FirstOrCreate(&warehouse).Error; err != nil {
+					return err
+				}
+				warehouseID = warehouse.ID
+				warehouses[warehouseKey] = warehouseID
+			}
+
+			doorKey := fmt.Sprintf("%d/%s", warehouseID, position)
+			doorID, ok := doors[doorKey]
+			if !ok {
+				door := models.GrainDoor{WarehouseID: warehouseID, Position: position}
+				if err := tx.Where(models.GrainDoor{WarehouseID: warehouseID, Position: position}).
+					FirstOrCreate(&door).Error; err != nil {
+					return err
+				}
+				doorID = door.ID
+				doors[doorKey] = doorID
+			}
+
+			// 迁移不视为用户修改，不更新修改时间
+			if err := tx.Unscoped().Model(&models.InspectionRecord{}).Where("id = ?", record.ID).
+				UpdateColumn("grain_door_id", doorID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// backfillRecordTemplates 点检模板上线前的历史点检记录使用默认模板的第一个版本，答案由固定字段生成
+// 历史记录不重新校验，保持原有内容
+func backfillRecordTemplates(db *gorm.DB) error {
+	var template models.ChecklistTemplate
+	if err := db.Where("code = ? AND version = ?", models.DefaultChecklistCode, 1).First(&template).Error; err != nil {
+		return err
+	}
+
+	var records []models.InspectionRecord
+	return db.Unscoped().Where("template_id IS NULL").
+		FindInBatches(&records, 200, func(tx *gorm.DB, batch int) error {
+			for _, record := range records {
+				answers, err := json.Marshal(record.LegacyAnswers())
+				if err != nil {
+					return err
+				}
+				if err := tx.Model(&models.InspectionRecord{}).Unscoped().Where("id = ?", record.ID).
+					UpdateColumns(map[string]interface{}{"template_id": template.ID, "answers": datatypes.JSON(answers)}).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// backfillRecordAttachments 将附件功能上线前点检记录中的图片登记为附件
+// 本地存在的图片补齐文件大小和摘要，上传人取记录的提交人；已有附件的记录跳过
+func backfillRecordAttachments(db *gorm.DB, uploadDir string) error {
+	var records []models.InspectionRecord
+	return db.Unscoped().
+		Where("NOT EXISTS (SELECT 1 FROM attachments WHERE attachments.record_id = inspection_records.id)").
+		Where("(images IS NOT NULL AND images NOT IN ('', 'null', '[]')) OR answers LIKE ?", `%"images"%`).
+		FindInBatches(&records, 200, func(tx *gorm.DB, batch int) error {
+			for _, record := range records {
+				for url, field := range record.ImageFields() {
+					recordID := record.ID
+					attachment := models.Attachment{RecordID: &recordID, Field: field, URL: url}
+					if record.UserID > 0 {
+						uploaderID := record.UserID
+						attachment.UploaderID = &uploaderID
+					}
+					if !record.CreatedAt.IsZero() {
+						attachment.CreatedAt = record.CreatedAt
+					}
+					if strings.HasPrefix(url, "/images/") {
+						attachment.Filename = path.Base(url)
+						size, hash, err := fileDigest(filepath.Join(uploadDir, attachment.Filename))
+						if err == nil {
+							attachment.Size = size
+							attachment.Hash = hash
+						}
+					}
+					if err := tx.Create(&attachment).Error; err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}).Error
+}
+
+// backfillAttachmentVariants 缩略图上线前登记的附件没有缩略版本，缩略图和中图的访问路径使用原图
+func backfillAttachmentVariants(db *gorm.DB) error {
+	if err := db.Model(&models.Attachment{}).Where("thumb_url IS NULL OR thumb_url = ''").
+		UpdateColumn("thumb_url", gorm.Expr("url")).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.Attachment{}).Where("medium_url IS NULL OR medium_url = ''").
+		UpdateColumn("medium_url", gorm.Expr("url")).Error
+}
+
+// fileDigest 读取本地文件的大小和 SHA-256 摘要
+func fileDigest(name string) (int64, string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// migrateRecordQRTokens 点检记录曾保存扫描的二维码原文，二维码可重复使用，原文泄露后可伪造扫码点检
+// 改为保存二维码版本和原文的摘要：取出版本号、计算摘要后删除该列，删除后不再执行。版本历史保持不变
+func migrateRecordQRTokens(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasColumn(&models.InspectionRecord{}, "qr_token") {
+		return nil
+	}
+	var rows []struct {
+		ID      int
+		QRToken string
+	}
+	if err := db.Table("inspection_records").Select("id, qr_token").
+		Where("qr_token IS NOT NULL AND qr_token <> ''").Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		columns := map[string]interface{}{"qr_token_hash": models.HashQRToken(row.QRToken)}
+		// 二维码内容格式为 dlm:door:<挡粮门ID>:<二维码版本>:<签名>
+		if parts := strings.Split(row.QRToken, ":"); len(parts) == 5 {
+			var version int
+			if _, err := fmt.Sscanf(parts[3], "%d", &version); err == nil {
+				columns["qr_version"] = version
+			}
+		}
+		if err := db.Table("inspection_records").Where("id = ?", row.ID).UpdateColumns(columns).Error; err != nil {
+			return err
+		}
+	}
+	if err := migrator.DropColumn(&models.InspectionRecord{}, "qr_token"); err != nil {
+		return err
+	}
+	// SQLite 删除列时重建表，索引需要重新建立
+	return db.AutoMigrate(&models.InspectionRecord{})
+}
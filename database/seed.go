@@ -0,0 +1,152 @@
+package database
+
+import (
+	"DLM_backend/models"
+
+	"gorm.io/gorm"
+)
+
+// seedRolesAndPermissions 写入内置角色和权限
+// 只有新建的角色或新增的权限才会按默认配置授权，不会覆盖管理员对已有角色的调整
+func seedRolesAndPermissions(db *gorm.DB) error {
+	newRoles := make(map[string]bool)
+	roles := make(map[string]*models.Role)
+	for _, def := range models.DefaultRoles {
+		role := models.Role{Name: def.Name}
+		result := db.Where(models.Role{Name: def.Name}).
+			Attrs(models.Role{DisplayName: def.DisplayName, Builtin: true}).
+			FirstOrCreate(&role)
+		if result.Error != nil {
+			return result.Error
+		}
+		newRoles[def.Name] = result.RowsAffected > 0
+		roles[def.Name] = &role
+	}
+
+	newPermissions := make(map[string]bool)
+	permissions := make(map[string]*models.Permission)
+	for _, def := range models.DefaultPermissions {
+		permission := models.Permission{Code: def.Code}
+		result := db.Where(models.Permission{Code: def.Code}).
+			Attrs(models.Permission{Description: def.Description}).
+			FirstOrCreate(&permission)
+		if result.Error != nil {
+			return result.Error
+		}
+		newPermissions[def.Code] = result.RowsAffected > 0
+		permissions[def.Code] = &permission
+	}
+
+	for _, def := range models.DefaultRoles {
+		var grant []models.Permission
+		for _, code := range def.Permissions {
+			if newRoles[def.Name] || newPermissions[code] {
+				grant = append(grant, *permissions[code])
+			}
+		}
+		if len(grant) == 0 {
+			continue
+		}
+		if err := db.Model(roles[def.Name]).Association("Permissions").Append(grant); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedChecklistTemplate 写入内置默认点检模板，对应点检记录原有的固定字段
+// 第一个版本不含异常填写要求，与要求上线前的记录一致；要求由 publishDefaultChecklistRules 作为新版本发布
+// 没有启用的模板时启用默认模板的最新版本，已发布的模板版本不会被修改
+func seedChecklistTemplate(db *gorm.DB) error {
+	template := models.ChecklistTemplate{Code: models.DefaultChecklistCode, Version: 1}
+	if err := db.Where(models.ChecklistTemplate{Code: models.DefaultChecklistCode, Version: 1}).
+		Attrs(models.ChecklistTemplate{Name: "挡粮门点检表", Items: checklistItemsWithoutRules(models.DefaultChecklistItems)}).
+		FirstOrCreate(&template).Error; err != nil {
+		return err
+	}
+
+	if err := publishDefaultChecklistRules(db); err != nil {
+		return err
+	}
+
+	var active int64
+	if err := db.Model(&models.ChecklistTemplate{}).Where("active = ?", true).Count(&active).Error; err != nil {
+		return err
+	}
+	if active == 0 {
+		var latest models.ChecklistTemplate
+		if err := db.Where("code = ?", models.DefaultChecklistCode).Order("version DESC").First(&latest).Error; err != nil {
+			return err
+		}
+		return db.Model(&latest).Update("active", true).Error
+	}
+	return nil
+}
+
+// checklistItemsWithoutRules 复制点检项并去除异常时的说明和照片要求
+func checklistItemsWithoutRules(items []models.ChecklistItem) []models.ChecklistItem {
+	result := make([]models.ChecklistItem, len(items))
+	for i, item := range items {
+		item.DescriptionRequired = false
+		item.MinPhotos = 0
+		result[i] = item
+	}
+	return result
+}
+
+// publishDefaultChecklistRules 为默认模板发布带异常填写要求的新版本，只执行一次
+// 默认模板的任一版本已设置要求时跳过；新版本基于最新版本，只为尚未设置要求的点检项补充要求，
+// 最新版本正在启用时改为启用新版本。已发布的版本保持不变，历史记录仍按原版本校验
+func publishDefaultChecklistRules(db *gorm.DB) error {
+	var templates []models.ChecklistTemplate
+	if err := db.Where("code = ?", models.DefaultChecklistCode).Order("version").Find(&templates).Error; err != nil {
+		return err
+	}
+	if len(templates) == 0 {
+		return nil
+	}
+	for _, template := range templates {
+		for _, item := range template.Items {
+			if item.DescriptionRequired || item.MinPhotos > 0 {
+				return nil
+			}
+		}
+	}
+
+	latest := templates[len(templates)-1]
+	items := make([]models.ChecklistItem, len(latest.Items))
+	copy(items, latest.Items)
+	changed := false
+	for i, item := range items {
+		for _, def := range models.DefaultChecklistItems {
+			if def.Field == item.Field && (def.DescriptionRequired || def.MinPhotos > 0) {
+				items[i].DescriptionRequired = def.DescriptionRequired
+				items[i].MinPhotos = def.MinPhotos
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		template := models.ChecklistTemplate{
+			Code:    latest.Code,
+			Name:    latest.Name,
+			Version: latest.Version + 1,
+			Items:   items,
+			Remark:  "点检项出现异常时必须填写说明并上传照片",
+		}
+		if err := tx.Create(&template).Error; err != nil {
+			return err
+		}
+		if !latest.Active {
+			return nil
+		}
+		if err := tx.Model(&models.ChecklistTemplate{}).Where("id = ?", latest.ID).Update("active", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&template).Update("active", true).Error
+	})
+}
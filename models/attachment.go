@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Attachment 定义上传的图片附件
+// 上传时尚未关联点检记录，记录保存时按图片列表关联到记录和点检项
+type Attachment struct {
+	ID          int        `json:"id" gorm:"primaryKey"`                            // 主键ID
+	RecordID    *int       `json:"record_id" gorm:"index"`                          // 所属点检记录ID，为空表示尚未关联
+	Field       string     `json:"field" gorm:"size:64;index"`                      // 所属点检项标识，为空表示整条记录的图片
+	URL         string     `json:"url" gorm:"size:255;not null;index"`              // 访问路径
+	ThumbURL    string     `json:"thumb_url" gorm:"size:255"`                       // 缩略图访问路径，原图较小时与原图相同
+	MediumURL   string     `json:"medium_url" gorm:"size:255"`                      // 中图访问路径，原图较小时与原图相同
+	Filename    string     `json:"filename" gorm:"size:255"`                        // 服务器上保存的文件名
+	ContentType string     `json:"content_type" gorm:"size:64"`                     // 文件类型
+	Size        int64      `json:"size"`                                            // 文件大小（字节）
+	Width       int        `json:"width"`                                           // 图片宽度（像素）
+	Height      int        `json:"height"`                                          // 图片高度（像素）
+	Hash        string     `json:"hash" gorm:"size:64;index"`                       // 文件内容的 SHA-256 摘要
+	CapturedAt  *time.Time `json:"captured_at"`                                     // 拍摄时间，由客户端提供
+	Caption     string     `json:"caption" gorm:"type:text"`                        // 图片说明
+	UploaderID  *int       `json:"uploader_id" gorm:"index"`                        // 上传人ID，历史图片为记录提交人，引用未登记的图片时为空
+	Uploader    *User      `json:"uploader,omitempty" gorm:"foreignKey:UploaderID"` // 上传人
+	CreatedAt   time.Time  `json:"created_at"`                                      // 上传时间
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Audit 定义创建/修改时间和操作人，嵌入到需要审计的模型中
+// CreatedAt/UpdatedAt 由 gorm 自动维护，CreatedBy/UpdatedBy 由服务层根据当前登录用户设置
+type Audit struct {
+	CreatedAt time.Time `json:"created_at" gorm:"index"` // 创建时间（提交时间）
+	UpdatedAt time.Time `json:"updated_at" gorm:"index"` // 最后修改时间
+	CreatedBy *int      `json:"created_by"`              // 创建人ID，系统生成或历史数据为空
+	UpdatedBy *int      `json:"updated_by"`              // 最后修改人ID
+}
+
+// SetCreator 设置创建人，同时作为最后修改人；userID 为 0 表示系统操作
+func (a *Audit) SetCreator(userID int) {
+	if userID == 0 {
+		return
+	}
+	a.CreatedBy = &userID
+	a.UpdatedBy = &userID
+}
+
+// SetUpdater 设置最后修改人；userID 为 0 表示系统操作
+func (a *Audit) SetUpdater(userID int) {
+	if userID == 0 {
+		return
+	}
+	a.UpdatedBy = &userID
+}
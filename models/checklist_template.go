@@ -0,0 +1,172 @@
+package models
+
+import "encoding/json"
+
+// ChecklistOption 点检项的一个可选状态
+type ChecklistOption struct {
+	Code     string `json:"code"`     // 状态编码，保存在点检记录中
+	Label    string `json:"label"`    // 显示名称，用于导出和前端展示
+	Abnormal bool   `json:"abnormal"` // 是否属于异常状态，异常状态会生成整改工单
+}
+
+// ChecklistItem 点检模板中的一个点检项
+type ChecklistItem struct {
+	Field               string            `json:"field"`                // 点检项标识，点检记录的 answers 以此为键
+	Label               string            `json:"label"`                // 点检项名称
+	Multiple            bool              `json:"multiple"`             // 是否多选，多选时答案为状态编码数组
+	Required            bool              `json:"required"`             // 是否必填
+	Normal              string            `json:"normal,omitempty"`     // 表示无异常的状态编码，多选时不能与其他状态同时选择
+	DescriptionRequired bool              `json:"description_required"` // 选择异常状态时是否必须填写说明
+	MinPhotos           int               `json:"min_photos"`           // 选择异常状态时至少需要的照片数，0 表示不要求
+	Options             []ChecklistOption `json:"options"`              // 允许的状态
+}
+
+// ChecklistTemplate 定义点检模板，同一编码的模板按版本递增，已发布的版本不再修改
+// 点检记录保存所使用的模板版本，模板调整后历史记录仍按原版本校验、展示和导出
+type ChecklistTemplate struct {
+	ID      int             `json:"id" gorm:"primaryKey"`                                          // 主键ID
+	Code    string          `json:"code" gorm:"size:64;not null;uniqueIndex:idx_template_version"` // 模板编码，同一模板的各版本编码相同
+	Name    string          `json:"name" gorm:"not null"`                                          // 模板名称
+	Version int             `json:"version" gorm:"not null;uniqueIndex:idx_template_version"`      // 版本号，从1开始
+	Items   []ChecklistItem `json:"items" gorm:"type:json;serializer:json"`                        // 点检项
+	Active  bool            `json:"active" gorm:"default:false;index"`                             // 是否为新点检记录使用的模板，同一时间只有一个
+	Remark  string          `json:"remark" gorm:"type:text"`                                       // 版本说明，例如依据的规范
+
+	Audit // 创建/修改时间和操作人
+}
+
+// DefaultChecklistCode 内置默认模板的编码
+const DefaultChecklistCode = "default"
+
+// DefaultChecklistItems 内置默认模板的点检项，与点检记录原有的固定字段一一对应
+// 任一点检项出现异常时必须填写说明并至少上传一张照片
+var DefaultChecklistItems = []ChecklistItem{
+	{
+		Field: "deformation_crack", Label: "挡粮门变形和裂痕情况", Required: true, Normal: "无变形或裂缝",
+		DescriptionRequired: true, MinPhotos: 1,
+		Options: []ChecklistOption{
+			{Code: "无变形或裂缝", Label: "无变形或裂缝"},
+			{Code: "有变形或裂缝", Label: "有变形或裂缝", Abnormal: true},
+		},
+	},
+	{
+		Field: "closure_status", Label: "闭合情况", Required: true, Normal: "关闭正常",
+		DescriptionRequired: true, MinPhotos: 1,
+		Options: []ChecklistOption{
+			{Code: "关闭正常", Label: "关闭正常"},
+			{Code: "关闭不严", Label: "关闭不严", Abnormal: true},
+		},
+	},
+	{
+		Field: "pin_status", Label: "栓销状况", Multiple: true, Required: true, Normal: "normal",
+		DescriptionRequired: true, MinPhotos: 1,
+		Options: []ChecklistOption{
+			{Code: "normal", Label: "正常"},
+			{Code: "loose", Label: "松动", Abnormal: true},
+			{Code: "deformed", Label: "变形", Abnormal: true},
+			{Code: "missing", Label: "缺失", Abnormal: true},
+		},
+	},
+	{
+		Field: "main_wall_status", Label: "主体墙状况", Multiple: true, Required: true, Normal: "normal",
+		DescriptionRequired: true, MinPhotos: 1,
+		Options: []ChecklistOption{
+			{Code: "normal", Label: "正常"},
+			{Code: "damaged", Label: "破损", Abnormal: true},
+			{Code: "cracked", Label: "有裂缝", Abnormal: true},
+		},
+	},
+	{
+		Field: "warehouse_foundation", Label: "仓门地基状况", Multiple: true, Required: true, Normal: "normal",
+		DescriptionRequired: true, MinPhotos: 1,
+		Options: []ChecklistOption{
+			{Code: "normal", Label: "正常"},
+			{Code: "frozen", Label: "冻胀", Abnormal: true},
+			{Code: "sinking", Label: "下沉", Abnormal: true},
+			{Code: "collapsed", Label: "塌陷", Abnormal: true},
+			{Code: "cracked", Label: "裂痕", Abnormal: true},
+		},
+	},
+	{
+		Field: "safety_rope_installed", Label: "安全绳（带）系留装置", Required: true, Normal: "已安装",
+		DescriptionRequired: true, MinPhotos: 1,
+		Options: []ChecklistOption{
+			{Code: "已安装", Label: "已安装"},
+			{Code: "未安装", Label: "未安装", Abnormal: true},
+		},
+	},
+}
+
+// FindItem 根据点检项标识查找点检项
+func (t *ChecklistTemplate) FindItem(field string) (ChecklistItem, bool) {
+	for _, item := range t.Items {
+		if item.Field == field {
+			return item, true
+		}
+	}
+	return ChecklistItem{}, false
+}
+
+// FindOption 根据状态编码查找可选状态
+func (i ChecklistItem) FindOption(code string) (ChecklistOption, bool) {
+	for _, option := range i.Options {
+		if option.Code == code {
+			return option, true
+		}
+	}
+	return ChecklistOption{}, false
+}
+
+// Allows 判断状态编码是否允许
+func (i ChecklistItem) Allows(code string) bool {
+	_, ok := i.FindOption(code)
+	return ok
+}
+
+// IsAbnormal 判断状态编码是否属于异常状态
+func (i ChecklistItem) IsAbnormal(code string) bool {
+	option, ok := i.FindOption(code)
+	return ok && option.Abnormal
+}
+
+// LabelOf 返回状态编码的显示名称，未知编码原样返回
+func (i ChecklistItem) LabelOf(code string) string {
+	if option, ok := i.FindOption(code); ok {
+		return option.Label
+	}
+	return code
+}
+
+// Codes 返回所有允许的状态编码
+func (i ChecklistItem) Codes() []string {
+	codes := make([]string, 0, len(i.Options))
+	for _, option := range i.Options {
+		codes = append(codes, option.Code)
+	}
+	return codes
+}
+
+// ChecklistAnswer 点检记录中一个点检项的填写结果
+type ChecklistAnswer struct {
+	Value       json.RawMessage `json:"value"`                 // 单选为状态编码字符串，多选为状态编码数组
+	Description string          `json:"description,omitempty"` // 说明
+	Images      []string        `json:"images,omitempty"`      // 该点检项的照片
+}
+
+// Codes 取出答案中的状态编码，单选答案返回一个元素；格式不正确时 ok 为 false
+func (a ChecklistAnswer) Codes() (codes []string, ok bool) {
+	if len(a.Value) == 0 || string(a.Value) == "null" {
+		return nil, true
+	}
+	var code string
+	if err := json.Unmarshal(a.Value, &code); err == nil {
+		if code == "" {
+			return nil, true
+		}
+		return []string{code}, true
+	}
+	if err := json.Unmarshal(a.Value, &codes); err == nil {
+		return codes, true
+	}
+	return nil, false
+}
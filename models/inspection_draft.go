@@ -0,0 +1,17 @@
+package models
+
+import "gorm.io/datatypes"
+
+// InspectionDraft 定义点检记录草稿，保存尚未填写完整的点检表单，提交后转为正式点检记录并删除
+// 草稿单独存放，不出现在点检记录列表、统计和导出中
+type InspectionDraft struct {
+	ID                int            `json:"id" gorm:"primaryKey"`          // 主键ID
+	UserID            int            `json:"user_id" gorm:"not null;index"` // 草稿所属用户ID
+	Data              datatypes.JSON `json:"data"`                          // 表单内容，字段与新增点检记录接口一致
+	Unit              string         `json:"unit"`                          // 单位，便于在草稿列表中识别
+	WarehouseNumber   string         `json:"warehouse_number"`              // 仓号
+	GrainDoorPosition string         `json:"grain_door_position"`           // 挡粮门位置
+	Version           int            `json:"version" gorm:"not null"`       // 版本号，每次保存加一，用于检测多设备同时编辑
+
+	Audit // 创建/修改时间和操作人
+}
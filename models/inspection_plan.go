@@ -0,0 +1,101 @@
+package models
+
+import "time"
+
+// 点检频次
+const (
+	FrequencyDaily   = "daily"   // 每天
+	FrequencyWeekly  = "weekly"  // 每周（周一至周日）
+	FrequencyMonthly = "monthly" // 每月
+)
+
+// ValidFrequency 判断点检频次是否有效
+func ValidFrequency(frequency string) bool {
+	switch frequency {
+	case FrequencyDaily, FrequencyWeekly, FrequencyMonthly:
+		return true
+	}
+	return false
+}
+
+// InspectionPlan 定义点检计划，针对单个挡粮门或整个仓房的所有挡粮门
+// 入粮季节内按 SeasonFrequency 点检，其余时间按 Frequency 点检
+type InspectionPlan struct {
+	ID              int        `json:"id" gorm:"primaryKey"`                               // 主键ID
+	Name            string     `json:"name"`                                               // 计划名称
+	GrainDoorID     *int       `json:"grain_door_id" gorm:"index"`                         // 挡粮门ID，与仓房ID二选一
+	GrainDoor       *GrainDoor `json:"grain_door,omitempty" gorm:"foreignKey:GrainDoorID"` // 挡粮门
+	WarehouseID     *int       `json:"warehouse_id" gorm:"index"`                          // 仓房ID，计划覆盖该仓房的所有挡粮门
+	Warehouse       *Warehouse `json:"warehouse,omitempty" gorm:"foreignKey:WarehouseID"`  // 仓房
+	AssigneeID      *int       `json:"assignee_id" gorm:"index"`                           // 执行人ID，为空时由仓房的保管责任人执行
+	Assignee        *User      `json:"assignee,omitempty" gorm:"foreignKey:AssigneeID"`    // 执行人
+	Frequency       string     `json:"frequency" gorm:"size:16;not null"`                  // 平时点检频次
+	SeasonFrequency string     `json:"season_frequency" gorm:"size:16"`                    // 入粮季节点检频次，为空时与平时相同
+	SeasonStart     string     `json:"season_start" gorm:"size:5"`                         // 入粮季节开始日期，格式 MM-DD
+	SeasonEnd       string     `json:"season_end" gorm:"size:5"`                           // 入粮季节结束日期，格式 MM-DD，可跨年
+	StartDate       time.Time  `json:"start_date"`                                         // 计划开始日期
+	EndDate         *time.Time `json:"end_date"`                                           // 计划结束日期，为空表示长期有效
+	Enabled         bool       `json:"enabled"`                                            // 是否启用
+	Remark          string     `json:"remark" gorm:"type:text"`                            // 备注
+
+	Audit // 创建/修改时间和操作人
+}
+
+// InSeason 判断时间是否在入粮季节内
+func (p *InspectionPlan) InSeason(t time.Time) bool {
+	if p.SeasonFrequency == "" || p.SeasonStart == "" || p.SeasonEnd == "" {
+		return false
+	}
+	day := t.Format("01-02")
+	if p.SeasonStart <= p.SeasonEnd {
+		return day >= p.SeasonStart && day <= p.SeasonEnd
+	}
+	// 跨年的季节，例如 11-01 至 02-28
+	return day >= p.SeasonStart || day <= p.SeasonEnd
+}
+
+// FrequencyAt 返回指定时间适用的点检频次
+func (p *InspectionPlan) FrequencyAt(t time.Time) string {
+	if p.InSeason(t) {
+		return p.SeasonFrequency
+	}
+	return p.Frequency
+}
+
+// PlanPeriod 返回指定时间所在的点检周期 [start, end)
+func (p *InspectionPlan) PlanPeriod(t time.Time) (start, end time.Time) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch p.FrequencyAt(t) {
+	case FrequencyWeekly:
+		offset := (int(day.Weekday()) + 6) % 7 // 周一为一周的第一天
+		start = day.AddDate(0, 0, -offset)
+		return start, start.AddDate(0, 0, 7)
+	case FrequencyMonthly:
+		start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 1, 0)
+	default:
+		return day, day.AddDate(0, 0, 1)
+	}
+}
+
+// PlanTask 点检任务，由点检计划和点检记录计算得到，不保存到数据库
+type PlanTask struct {
+	PlanID         int        `json:"plan_id"`         // 点检计划ID
+	PlanName       string     `json:"plan_name"`       // 点检计划名称
+	GrainDoor      *GrainDoor `json:"grain_door"`      // 挡粮门及其仓房、单位
+	AssigneeID     *int       `json:"assignee_id"`     // 执行人ID
+	Frequency      string     `json:"frequency"`       // 当前适用的点检频次
+	PeriodStart    time.Time  `json:"period_start"`    // 当前周期开始时间
+	PeriodEnd      time.Time  `json:"period_end"`      // 当前周期结束时间
+	LastInspection *time.Time `json:"last_inspection"` // 最近一次点检时间
+	DueAt          time.Time  `json:"due_at"`          // 下一次点检的截止时间
+	Status         string     `json:"status"`          // 任务状态
+	OverdueDays    int        `json:"overdue_days"`    // 逾期天数
+}
+
+// 点检任务状态
+const (
+	TaskStatusDone    = "done"    // 本周期已点检
+	TaskStatusPending = "pending" // 本周期待点检
+	TaskStatusOverdue = "overdue" // 已逾期
+)
@@ -1,9 +1,14 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
 	"time"
 
 	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
 // InspectionRecord 定义挡粮门点检记录模型
@@ -11,9 +16,11 @@ type InspectionRecord struct {
 	ID                             int            `json:"id" gorm:"primaryKey"`                              // 主键ID
 	UserID                         int            `json:"user_id" gorm:"default:null"`                       // 用户ID外键
 	User                           User           `json:"user" gorm:"foreignKey:UserID"`                     // 用户关联
+	UnitID                         *int           `json:"unit_id" gorm:"index"`                              // 单位ID，历史数据迁移时按单位名称补齐
 	Unit                           string         `json:"unit" gorm:"not null"`                              // 单位
 	WarehouseNumber                string         `json:"warehouse_number" gorm:"not null"`                  // 仓号
 	GrainDoorPosition              string         `json:"grain_door_position" gorm:"not null"`               // 挡粮门位置
+	GrainDoorID                    *int           `json:"grain_door_id" gorm:"index"`                        // 挡粮门ID，仓号和门位置文本保留用于历史数据
 	Caretaker                      string         `json:"caretaker" gorm:"not null"`                         // 保管责任人
 	InspectionTime                 time.Time      `json:"inspection_time" gorm:"not null"`                   // 检查时间
 	DeformationCrack               string         `json:"deformation_crack" gorm:"not null"`                 // 挡粮门变形和裂痕情况 (无异常/有异常)
@@ -32,4 +39,152 @@ type InspectionRecord struct {
 	Signature                      string         `json:"signature" gorm:"not null"`                         // 责任人签名
 	ContactNumber                  string         `json:"contact_number" gorm:"not null"`                    // 联系电话
 	Images                         datatypes.JSON `json:"images"`                                            // 图片列表
+	TemplateID                     *int           `json:"template_id" gorm:"index"`                          // 填写时使用的点检模板版本ID
+	Answers                        datatypes.JSON `json:"answers" gorm:"type:json"`                          // 按点检模板填写的各点检项结果，以点检项标识为键
+
+	Audit // 创建/修改时间和操作人
+
+	Version int `json:"version" gorm:"not null;default:1"` // 版本号，每次修改内容加一，离线同步据此检测冲突
+
+	QRVerified  bool   `json:"qr_verified" gorm:"default:false"`       // 是否通过扫描挡粮门二维码确认到场
+	QRVersion   int    `json:"qr_version,omitempty"`                   // 扫描的二维码版本
+	QRTokenHash string `json:"qr_token_hash,omitempty" gorm:"size:64"` // 扫描的二维码内容的 SHA-256 摘要，原文可伪造扫码点检，不保存
+
+	ReviewStatus string     `json:"review_status" gorm:"size:16;index"`              // 审核状态
+	ReviewerID   *int       `json:"reviewer_id"`                                     // 审核人ID
+	Reviewer     *User      `json:"reviewer,omitempty" gorm:"foreignKey:ReviewerID"` // 审核人
+	ReviewedAt   *time.Time `json:"reviewed_at"`                                     // 审核时间
+	RejectReason string     `json:"reject_reason" gorm:"type:text"`                  // 驳回原因，重新提交后保留供审核人参考
+
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"` // 删除时间，非空表示已移入回收站
+	DeletedBy *int           `json:"deleted_by,omitempty"`              // 删除操作人ID
+}
+
+// 点检记录审核状态
+// 新提交的记录为 submitted，审核通过为 approved，驳回为 rejected；
+// 被驳回或已通过的记录修改后变为 resubmitted，需要重新审核
+const (
+	ReviewSubmitted   = "submitted"   // 已提交，待审核
+	ReviewApproved    = "approved"    // 审核通过
+	ReviewRejected    = "rejected"    // 已驳回
+	ReviewResubmitted = "resubmitted" // 修改后重新提交，待审核
+)
+
+// ReviewStatusLabels 审核状态的显示名称，用于导出
+var ReviewStatusLabels = map[string]string{
+	ReviewSubmitted:   "待审核",
+	ReviewApproved:    "审核通过",
+	ReviewRejected:    "已驳回",
+	ReviewResubmitted: "重新提交待审核",
+}
+
+// AwaitingReview 判断记录是否在等待审核
+func (r *InspectionRecord) AwaitingReview() bool {
+	return r.ReviewStatus == ReviewSubmitted || r.ReviewStatus == ReviewResubmitted
+}
+
+// ChecklistAnswers 解析按点检模板填写的各点检项结果
+func (r *InspectionRecord) ChecklistAnswers() (map[string]ChecklistAnswer, error) {
+	answers := map[string]ChecklistAnswer{}
+	if len(r.Answers) == 0 || string(r.Answers) == "null" {
+		return answers, nil
+	}
+	if err := json.Unmarshal(r.Answers, &answers); err != nil {
+		return nil, err
+	}
+	return answers, nil
+}
+
+// LegacyAnswers 由默认模板对应的固定字段生成各点检项结果，用于历史记录和仍按固定字段提交的客户端
+// 未填写的点检项不包含在结果中
+func (r *InspectionRecord) LegacyAnswers() map[string]ChecklistAnswer {
+	answers := map[string]ChecklistAnswer{}
+	single := func(field, code, description string) {
+		if code != "" {
+			value, _ := json.Marshal(code)
+			answers[field] = ChecklistAnswer{Value: value, Description: description}
+		}
+	}
+	multiple := func(field string, codes datatypes.JSON, description string) {
+		if len(codes) > 0 && string(codes) != "null" {
+			answers[field] = ChecklistAnswer{Value: json.RawMessage(codes), Description: description}
+		}
+	}
+	single("deformation_crack", r.DeformationCrack, r.DeformationCrackDescription)
+	single("closure_status", r.ClosureStatus, r.ClosureDescription)
+	multiple("pin_status", r.PinStatus, r.PinDescription)
+	multiple("main_wall_status", r.MainWallStatus, r.MainWallDescription)
+	multiple("warehouse_foundation", r.WarehouseFoundation, r.WarehouseFoundationDescription)
+	single("safety_rope_installed", r.SafetyRopeInstalled, r.SafetyRopeDescription)
+	return answers
+}
+
+// SetLegacyColumns 将与默认模板相同的点检项结果同步到固定字段，保证按固定字段查询和展示的客户端仍然可用
+func (r *InspectionRecord) SetLegacyColumns(answers map[string]ChecklistAnswer) {
+	single := func(field string, code, description *string) {
+		if answer, ok := answers[field]; ok {
+			codes, _ := answer.Codes()
+			*code = ""
+			if len(codes) > 0 {
+				*code = codes[0]
+			}
+			*description = answer.Description
+		}
+	}
+	multiple := func(field string, codes *datatypes.JSON, description *string) {
+		if answer, ok := answers[field]; ok {
+			*codes = datatypes.JSON(answer.Value)
+			*description = answer.Description
+		}
+	}
+	single("deformation_crack", &r.DeformationCrack, &r.DeformationCrackDescription)
+	single("closure_status", &r.ClosureStatus, &r.ClosureDescription)
+	multiple("pin_status", &r.PinStatus, &r.PinDescription)
+	multiple("main_wall_status", &r.MainWallStatus, &r.MainWallDescription)
+	multiple("warehouse_foundation", &r.WarehouseFoundation, &r.WarehouseFoundationDescription)
+	single("safety_rope_installed", &r.SafetyRopeInstalled, &r.SafetyRopeDescription)
+}
+
+// ImageFields 取出点检记录引用的所有图片及所属点检项，整条记录的图片点检项为空
+func (r *InspectionRecord) ImageFields() map[string]string {
+	images := map[string]string{}
+	var recordImages []string
+	if err := json.Unmarshal(r.Images, &recordImages); err == nil {
+		for _, url := range recordImages {
+			if url = strings.TrimSpace(url); url != "" {
+				images[url] = ""
+			}
+		}
+	}
+	// 同一图片同时出现在点检项和整条记录中时归属点检项
+	if answers, err := r.ChecklistAnswers(); err == nil {
+		for field, answer := range answers {
+			for _, url := range answer.Images {
+				if url = strings.TrimSpace(url); url != "" {
+					images[url] = field
+				}
+			}
+		}
+	}
+	return images
+}
+
+// SetQRScan 记录扫描的挡粮门二维码，只保存二维码版本和内容摘要
+func (r *InspectionRecord) SetQRScan(token string, version int) {
+	r.QRVerified = true
+	r.QRVersion = version
+	r.QRTokenHash = HashQRToken(token)
+}
+
+// ClearQRScan 清除扫码结果，记录改到其他挡粮门时原扫码结果不再适用
+func (r *InspectionRecord) ClearQRScan() {
+	r.QRVerified = false
+	r.QRVersion = 0
+	r.QRTokenHash = ""
+}
+
+// HashQRToken 计算二维码内容的 SHA-256 摘要
+func HashQRToken(token string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// 点检记录版本的操作类型
+const (
+	RevisionActionBaseline = "baseline" // 历史记录首次修改前补记的原始版本
+	RevisionActionCreate   = "create"   // 新建
+	RevisionActionUpdate   = "update"   // 修改
+	RevisionActionDelete   = "delete"   // 移入回收站
+	RevisionActionRestore  = "restore"  // 从回收站恢复
+	RevisionActionRevert   = "revert"   // 回退到历史版本
+	RevisionActionApprove  = "approve"  // 审核通过
+	RevisionActionReject   = "reject"   // 审核驳回
+)
+
+// InspectionRevision 定义点检记录的版本模型，每次变更追加一条，不允许修改和删除
+type InspectionRevision struct {
+	ID        int            `json:"id" gorm:"primaryKey"`                                     // 主键ID
+	RecordID  int            `json:"record_id" gorm:"not null;uniqueIndex:idx_record_version"` // 点检记录ID
+	Version   int            `json:"version" gorm:"not null;uniqueIndex:idx_record_version"`   // 版本号，从1开始递增
+	Action    string         `json:"action" gorm:"size:16;not null"`                           // 操作类型
+	UserID    int            `json:"user_id" gorm:"default:null"`                              // 操作人ID
+	User      User           `json:"user" gorm:"foreignKey:UserID"`                            // 操作人
+	Snapshot  datatypes.JSON `json:"snapshot"`                                                 // 变更后的完整记录内容
+	Changes   datatypes.JSON `json:"changes"`                                                  // 字段级变更列表
+	CreatedAt time.Time      `json:"created_at"`                                               // 变更时间
+}
+
+// FieldChange 单个字段的变更，Old/New 为字段在记录 JSON 中的取值
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// LoginAttempt 定义登录审计日志模型，记录每一次登录尝试
+type LoginAttempt struct {
+	ID        int       `json:"id" gorm:"primaryKey"`          // 主键ID
+	Username  string    `json:"username" gorm:"size:64;index"` // 尝试登录的用户名，微信登录时为绑定的用户名
+	Role      string    `json:"role" gorm:"size:32"`           // 尝试登录的角色
+	Method    string    `json:"method" gorm:"size:16"`         // 登录方式 (password/wechat)
+	IP        string    `json:"ip" gorm:"size:64;index"`       // 客户端IP
+	UserAgent string    `json:"user_agent"`                    // 客户端标识
+	Success   bool      `json:"success"`                       // 是否登录成功
+	Reason    string    `json:"reason"`                        // 失败原因
+	CreatedAt time.Time `json:"created_at" gorm:"index"`       // 尝试时间
+}
+
+// LoginLock 定义登录失败计数与锁定状态，按用户名和IP分别统计
+type LoginLock struct {
+	ID            int        `json:"id" gorm:"primaryKey"`                     // 主键ID
+	Key           string     `json:"key" gorm:"size:128;uniqueIndex;not null"` // 统计维度，例如 "user:张三"、"ip:10.0.0.1"
+	Failures      int        `json:"failures"`                                 // 当前连续失败次数
+	LockCount     int        `json:"lock_count"`                               // 累计锁定次数，用于计算指数退避的锁定时长
+	LockedUntil   *time.Time `json:"locked_until"`                             // 锁定截止时间
+	LastFailureAt time.Time  `json:"last_failure_at"`                          // 最近一次失败时间
+}
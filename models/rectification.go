@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// 整改工单状态，按 open -> in_progress -> fixed -> verified 依次流转，复查未通过时 fixed -> in_progress 退回重新整改
+const (
+	RectificationOpen       = "open"        // 待整改
+	RectificationInProgress = "in_progress" // 整改中
+	RectificationFixed      = "fixed"       // 已整改，待复查
+	RectificationVerified   = "verified"    // 复查通过，已关闭
+)
+
+// RectificationTicket 定义整改工单，点检记录中每个异常项自动生成一个工单
+type RectificationTicket struct {
+	ID               int               `json:"id" gorm:"primaryKey"`                                      // 主键ID
+	RecordID         int               `json:"record_id" gorm:"not null;uniqueIndex:idx_record_item"`     // 发现异常的点检记录ID
+	Record           *InspectionRecord `json:"record,omitempty" gorm:"foreignKey:RecordID"`               // 发现异常的点检记录
+	UnitID           *int              `json:"unit_id" gorm:"index"`                                      // 所属单位ID，用于按单位范围过滤
+	GrainDoorID      *int              `json:"grain_door_id" gorm:"index"`                                // 挡粮门ID
+	Field            string            `json:"field" gorm:"size:64;not null;uniqueIndex:idx_record_item"` // 异常的点检项字段名
+	Item             string            `json:"item" gorm:"size:64;not null;uniqueIndex:idx_record_item"`  // 异常的状态编码
+	Title            string            `json:"title"`                                                     // 工单标题，例如 "栓销状况：松动"
+	Description      string            `json:"description" gorm:"type:text"`                              // 异常说明，取自点检记录
+	AssigneeID       *int              `json:"assignee_id" gorm:"index"`                                  // 整改责任人ID
+	Assignee         *User             `json:"assignee,omitempty" gorm:"foreignKey:AssigneeID"`           // 整改责任人
+	Deadline         time.Time         `json:"deadline"`                                                  // 整改期限
+	Status           string            `json:"status" gorm:"size:16;not null;index"`                      // 工单状态
+	BeforeImages     datatypes.JSON    `json:"before_images"`                                             // 整改前图片，取自点检记录
+	AfterImages      datatypes.JSON    `json:"after_images"`                                              // 整改后图片
+	FixNote          string            `json:"fix_note" gorm:"type:text"`                                 // 整改说明
+	StartedAt        *time.Time        `json:"started_at"`                                                // 开始整改时间
+	FixedAt          *time.Time        `json:"fixed_at"`                                                  // 整改完成时间
+	FixedBy          *int              `json:"fixed_by"`                                                  // 整改完成操作人ID
+	VerifiedAt       *time.Time        `json:"verified_at"`                                               // 复查通过时间
+	VerifiedBy       *int              `json:"verified_by"`                                               // 复查人ID
+	FollowUpRecordID *int              `json:"follow_up_record_id"`                                       // 复查使用的同一挡粮门后续点检记录ID
+	ReturnReason     string            `json:"return_reason" gorm:"type:text"`                            // 最近一次复查未通过、退回重新整改的原因
+	Overdue          bool              `json:"overdue" gorm:"-"`                                          // 是否已超过整改期限仍未整改完成
+
+	Audit // 创建/修改时间和操作人
+}
+
+// AfterFind 查询后计算是否逾期
+func (t *RectificationTicket) AfterFind(tx *gorm.DB) error {
+	t.Overdue = (t.Status == RectificationOpen || t.Status == RectificationInProgress) && time.Now().After(t.Deadline)
+	return nil
+}
@@ -0,0 +1,111 @@
+package models
+
+// Permission 定义权限模型，Code 形如 "inspection:create"
+type Permission struct {
+	ID          int    `json:"id" gorm:"primaryKey"`                     // 主键ID
+	Code        string `json:"code" gorm:"size:64;uniqueIndex;not null"` // 权限编码
+	Description string `json:"description"`                              // 权限说明
+}
+
+// Role 定义角色模型，用户通过 User.Role 关联到角色名称
+type Role struct {
+	ID          int          `json:"id" gorm:"primaryKey"`                          // 主键ID
+	Name        string       `json:"name" gorm:"size:32;uniqueIndex;not null"`      // 角色名称，例如 "keeper"、"admin"
+	DisplayName string       `json:"display_name"`                                  // 显示名称，例如 "保管员"
+	Builtin     bool         `json:"builtin" gorm:"default:false"`                  // 是否为内置角色，内置角色不可删除
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions"` // 角色拥有的权限
+
+	Audit // 创建/修改时间和操作人
+}
+
+// 权限编码
+const (
+	PermInspectionCreate    = "inspection:create"     // 新增点检记录
+	PermInspectionRead      = "inspection:read"       // 查询点检记录
+	PermInspectionUpdateOwn = "inspection:update:own" // 修改本人的点检记录
+	PermInspectionUpdateAny = "inspection:update:any" // 修改任意点检记录
+	PermInspectionDeleteOwn = "inspection:delete:own" // 删除本人的点检记录
+	PermInspectionDeleteAny = "inspection:delete:any" // 删除任意点检记录
+	PermImageUpload         = "image:upload"          // 上传图片
+	PermExportRun           = "export:run"            // 导出点检记录
+	PermUserManage          = "user:manage"           // 管理用户账号
+	PermRoleManage          = "role:manage"           // 管理角色与权限
+	PermUnitManage          = "unit:manage"           // 管理单位
+	PermUnitAll             = "unit:all"              // 跨单位查看和处理所有记录（总部）
+	PermAuditRead           = "audit:read"            // 查看登录审计日志和锁定记录
+	PermRecycleManage       = "recycle:manage"        // 管理回收站中已删除的点检记录
+	PermInspectionRevert    = "inspection:revert"     // 将点检记录回退到历史版本
+	PermWarehouseManage     = "warehouse:manage"      // 管理仓房和挡粮门主数据
+	PermPlanManage          = "plan:manage"           // 管理点检计划并查看逾期任务
+	PermRectificationManage = "rectification:manage"  // 管理和复查所有整改工单
+	PermInspectionReview    = "inspection:review"     // 审核点检记录
+	PermChecklistManage     = "checklist:manage"      // 管理点检模板
+)
+
+// DefaultPermissions 系统内置的权限及说明，启动时自动写入数据库
+var DefaultPermissions = []Permission{
+	{Code: PermInspectionCreate, Description: "新增点检记录"},
+	{Code: PermInspectionRead, Description: "查询点检记录"},
+	{Code: PermInspectionUpdateOwn, Description: "修改本人的点检记录"},
+	{Code: PermInspectionUpdateAny, Description: "修改任意点检记录"},
+	{Code: PermInspectionDeleteOwn, Description: "删除本人的点检记录"},
+	{Code: PermInspectionDeleteAny, Description: "删除任意点检记录"},
+	{Code: PermImageUpload, Description: "上传图片"},
+	{Code: PermExportRun, Description: "导出点检记录"},
+	{Code: PermUserManage, Description: "管理用户账号"},
+	{Code: PermRoleManage, Description: "管理角色与权限"},
+	{Code: PermUnitManage, Description: "管理单位"},
+	{Code: PermUnitAll, Description: "跨单位查看和处理所有记录"},
+	{Code: PermAuditRead, Description: "查看登录审计日志和锁定记录"},
+	{Code: PermRecycleManage, Description: "管理回收站中已删除的点检记录"},
+	{Code: PermInspectionRevert, Description: "将点检记录回退到历史版本"},
+	{Code: PermWarehouseManage, Description: "管理仓房和挡粮门主数据"},
+	{Code: PermPlanManage, Description: "管理点检计划并查看逾期任务"},
+	{Code: PermRectificationManage, Description: "管理和复查所有整改工单"},
+	{Code: PermInspectionReview, Description: "审核点检记录"},
+	{Code: PermChecklistManage, Description: "管理点检模板"},
+}
+
+// DefaultRole 内置角色及其默认权限
+type DefaultRole struct {
+	Name        string
+	DisplayName string
+	Permissions []string
+}
+
+// DefaultRoles 系统内置的角色，启动时自动写入数据库
+// 新增的内置权限只会授予此处列出的角色一次，之后可由管理员自行调整
+var DefaultRoles = []DefaultRole{
+	{
+		Name:        "keeper",
+		DisplayName: "保管员",
+		Permissions: []string{
+			PermInspectionCreate, PermInspectionRead,
+			PermInspectionUpdateOwn, PermInspectionDeleteOwn,
+			PermImageUpload,
+		},
+	},
+	{
+		Name:        "admin",
+		DisplayName: "管理员",
+		Permissions: []string{
+			PermInspectionCreate, PermInspectionRead,
+			PermInspectionUpdateOwn, PermInspectionUpdateAny,
+			PermInspectionDeleteOwn, PermInspectionDeleteAny,
+			PermImageUpload, PermExportRun,
+			PermUserManage, PermRoleManage,
+			PermUnitManage, PermUnitAll,
+			PermAuditRead, PermRecycleManage,
+			PermInspectionRevert, PermWarehouseManage,
+			PermPlanManage, PermRectificationManage,
+			PermInspectionReview, PermChecklistManage,
+		},
+	},
+	{
+		Name:        "hq",
+		DisplayName: "总部",
+		Permissions: []string{
+			PermInspectionRead, PermExportRun, PermUnitAll,
+		},
+	},
+}
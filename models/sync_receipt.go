@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// SyncReceipt 记录离线同步已处理的幂等键，同一用户重复提交相同幂等键时直接返回原结果
+type SyncReceipt struct {
+	ID             int       `json:"id" gorm:"primaryKey"`                                                      // 主键ID
+	UserID         int       `json:"user_id" gorm:"not null;uniqueIndex:idx_user_idempotency"`                  // 提交用户ID
+	IdempotencyKey string    `json:"idempotency_key" gorm:"size:128;not null;uniqueIndex:idx_user_idempotency"` // 客户端生成的幂等键
+	RecordID       int       `json:"record_id" gorm:"not null"`                                                 // 新建或修改的点检记录ID
+	Action         string    `json:"action" gorm:"size:16;not null"`                                            // 处理方式：created 或 updated
+	CreatedAt      time.Time `json:"created_at"`                                                                // 处理时间
+}
+
+// 离线同步的处理方式
+const (
+	SyncActionCreated = "created" // 新建点检记录
+	SyncActionUpdated = "updated" // 修改点检记录
+)
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// RefreshToken 定义刷新令牌模型，数据库中只保存令牌的哈希值
+type RefreshToken struct {
+	ID           int        `json:"id" gorm:"primaryKey"`                    // 主键ID
+	UserID       int        `json:"user_id" gorm:"index;not null"`           // 所属用户ID
+	TokenHash    string     `json:"-" gorm:"size:64;uniqueIndex;not null"`   // 令牌的 SHA-256 哈希
+	FamilyID     string     `json:"family_id" gorm:"size:32;index;not null"` // 令牌族ID，同一次登录轮换产生的令牌属于同一族
+	TokenVersion int        `json:"-"`                                       // 签发时用户的令牌版本号
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null"`              // 过期时间
+	RevokedAt    *time.Time `json:"revoked_at"`                              // 吊销时间，轮换或退出登录后设置
+	ReplacedByID *int       `json:"replaced_by_id"`                          // 轮换后替代它的新令牌ID
+	UserAgent    string     `json:"user_agent"`                              // 签发时的客户端标识
+	IP           string     `json:"ip"`                                      // 签发时的客户端IP
+	CreatedAt    time.Time  `json:"created_at"`                              // 签发时间
+}
+
+// RevokedToken 定义已吊销的访问令牌，在令牌自然过期前拒绝其访问
+type RevokedToken struct {
+	ID        int       `json:"id" gorm:"primaryKey"`                    // 主键ID
+	JTI       string    `json:"jti" gorm:"size:32;uniqueIndex;not null"` // 访问令牌ID
+	ExpiresAt time.Time `json:"expires_at" gorm:"index;not null"`        // 访问令牌的过期时间，过期后可清理
+	CreatedAt time.Time `json:"created_at"`                              // 吊销时间
+}
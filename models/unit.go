@@ -0,0 +1,11 @@
+package models
+
+// Unit 定义单位（组织）模型，用户和点检记录都归属于单位
+type Unit struct {
+	ID     int    `json:"id" gorm:"primaryKey"`                      // 主键ID
+	Name   string `json:"name" gorm:"size:128;uniqueIndex;not null"` // 单位名称
+	Code   string `json:"code" gorm:"size:32"`                       // 单位编码
+	Remark string `json:"remark" gorm:"type:text"`                   // 备注
+
+	Audit // 创建/修改时间和操作人
+}
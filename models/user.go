@@ -4,8 +4,16 @@ package models
 type User struct {
 	ID       int    `json:"id" gorm:"primaryKey"` // 主键ID
 	Username string `json:"username"`
-	Password string `json:"-" gorm:"column:password"` // 使用json:"-"来在JSON序列化时忽略该字段
-	Role     string `json:"role"`                     // 例如 "admin" 或 "keeper"
-	Name     string `json:"name"`                     // 个人姓名
-	Phone    string `json:"phone"`                    // 手机号
+	Password string `json:"-" gorm:"column:password"`      // bcrypt 哈希，使用json:"-"来在JSON序列化时忽略该字段
+	Role     string `json:"role"`                          // 角色名称，对应 roles 表，例如 "admin" 或 "keeper"
+	Name     string `json:"name"`                          // 个人姓名
+	Phone    string `json:"phone"`                         // 手机号
+	Disabled bool   `json:"disabled" gorm:"default:false"` // 是否已停用，停用后无法登录
+
+	TokenVersion int     `json:"-" gorm:"default:0"`           // 令牌版本号，修改密码后递增以使已签发的令牌失效
+	OpenID       *string `json:"-" gorm:"size:64;uniqueIndex"` // 绑定的微信小程序 openid，未绑定时为空
+
+	Units []Unit `json:"units,omitempty" gorm:"many2many:user_units"` // 所属单位
+
+	Audit // 创建/修改时间和操作人
 }
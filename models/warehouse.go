@@ -0,0 +1,101 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Warehouse 定义仓房主数据，仓号在同一单位内唯一
+type Warehouse struct {
+	ID          int         `json:"id" gorm:"primaryKey"`                                          // 主键ID
+	UnitID      int         `json:"unit_id" gorm:"not null;uniqueIndex:idx_unit_warehouse"`        // 所属单位ID
+	Unit        *Unit       `json:"unit,omitempty" gorm:"foreignKey:UnitID"`                       // 所属单位
+	Number      string      `json:"number" gorm:"size:32;not null;uniqueIndex:idx_unit_warehouse"` // 仓号，按 NormalizeWarehouseNumber 规范化后保存
+	Name        string      `json:"name"`                                                          // 仓房名称，例如 "1号仓"
+	Capacity    float64     `json:"capacity"`                                                      // 设计仓容（吨）
+	CaretakerID *int        `json:"caretaker_id"`                                                  // 保管责任人ID
+	Caretaker   *User       `json:"caretaker,omitempty" gorm:"foreignKey:CaretakerID"`             // 保管责任人
+	Remark      string      `json:"remark" gorm:"type:text"`                                       // 备注
+	Doors       []GrainDoor `json:"doors,omitempty" gorm:"foreignKey:WarehouseID"`                 // 仓房的挡粮门
+
+	Audit // 创建/修改时间和操作人
+}
+
+// GrainDoor 定义挡粮门主数据，门位置在同一仓房内唯一
+type GrainDoor struct {
+	ID          int        `json:"id" gorm:"primaryKey"`                                            // 主键ID
+	WarehouseID int        `json:"warehouse_id" gorm:"not null;uniqueIndex:idx_warehouse_door"`     // 所属仓房ID
+	Warehouse   *Warehouse `json:"warehouse,omitempty" gorm:"foreignKey:WarehouseID"`               // 所属仓房
+	Position    string     `json:"position" gorm:"size:64;not null;uniqueIndex:idx_warehouse_door"` // 门位置，按 NormalizeDoorPosition 规范化后保存
+	DoorType    string     `json:"door_type"`                                                       // 挡粮门类型，例如 "插板式"、"液压式"
+	InstallDate *time.Time `json:"install_date"`                                                    // 安装日期
+	QRVersion   int        `json:"qr_version" gorm:"default:1"`                                     // 二维码版本，重新生成后旧二维码失效
+	Remark      string     `json:"remark" gorm:"type:text"`                                         // 备注
+
+	Audit // 创建/修改时间和操作人
+}
+
+// normalizeText 去除空白并将全角字母数字转换为半角、字母转为大写
+func normalizeText(s string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(s) {
+		switch {
+		case unicode.IsSpace(r):
+			continue
+		case r >= '０' && r <= '９', r >= 'Ａ' && r <= 'Ｚ', r >= 'ａ' && r <= 'ｚ':
+			r -= 0xFEE0 // 全角字符与半角字符的编码差
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// trimLeadingZeros 纯数字时去掉前导零，例如 "01" -> "1"
+func trimLeadingZeros(s string) string {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return s
+		}
+	}
+	if trimmed := strings.TrimLeft(s, "0"); trimmed != "" {
+		return trimmed
+	}
+	if s != "" {
+		return "0"
+	}
+	return s
+}
+
+// NormalizeWarehouseNumber 规范化仓号，"1号仓"、"1仓"、"01" 都规范化为 "1"
+func NormalizeWarehouseNumber(number string) string {
+	number = normalizeText(number)
+	number = strings.TrimPrefix(number, "第")
+	for _, suffix := range []string{"号仓房", "号仓", "仓房", "仓", "号"} {
+		if strings.HasSuffix(number, suffix) && number != suffix {
+			number = strings.TrimSuffix(number, suffix)
+			break
+		}
+	}
+	return trimLeadingZeros(number)
+}
+
+// NormalizeDoorPosition 规范化挡粮门位置，"1号门"、"01门"、"１" 都规范化为 "1"
+func NormalizeDoorPosition(position string) string {
+	position = normalizeText(position)
+	for _, suffix := range []string{"号门", "门", "号"} {
+		if strings.HasSuffix(position, suffix) && position != suffix {
+			position = strings.TrimSuffix(position, suffix)
+			break
+		}
+	}
+	return trimLeadingZeros(position)
+}
+
+// DefaultWarehouseName 根据规范化后的仓号生成仓房名称，数字仓号生成 "1号仓"
+func DefaultWarehouseName(number string) string {
+	if number != "" && trimLeadingZeros(number) == number && strings.Trim(number, "0123456789") == "" {
+		return number + "号仓"
+	}
+	return number
+}
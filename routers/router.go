@@ -2,6 +2,7 @@ package routers
 
 import (
 	"DLM_backend/controllers"
+	"DLM_backend/models"
 	"DLM_backend/utils"
 
 	"github.com/gin-gonic/gin"
@@ -13,15 +14,44 @@ func SetupRouter() *gin.Engine {
 
 	// 公共路由
 	r.POST("/login", controllers.Login)
+	r.POST("/token/refresh", controllers.RefreshToken)
+	r.POST("/wx/login", controllers.WechatLogin)
 
 	// JWT 鉴权的路由组
 	authorized := r.Group("/", utils.JWTAuthMiddleware())
 	{
+		// 退出登录
+		authorized.POST("/logout", controllers.Logout)
+
 		// 点检记录相关接口
-		authorized.POST("/inspection", controllers.CreateInspection)
-		authorized.GET("/inspection", controllers.GetInspections)
-		authorized.PUT("/inspection", controllers.UpdateInspection)
-		authorized.DELETE("/inspection/:id", controllers.DeleteInspection)
+		authorized.POST("/inspection",
+			utils.RequirePermission(models.PermInspectionCreate), controllers.CreateInspection)
+		authorized.GET("/inspection",
+			utils.RequirePermission(models.PermInspectionRead), controllers.GetInspections)
+		authorized.PUT("/inspection",
+			utils.RequirePermission(models.PermInspectionUpdateOwn, models.PermInspectionUpdateAny), controllers.UpdateInspection)
+		authorized.DELETE("/inspection/:id",
+			utils.RequirePermission(models.PermInspectionDeleteOwn, models.PermInspectionDeleteAny), controllers.DeleteInspection)
+		authorized.GET("/inspection/statuses", controllers.GetInspectionStatuses)
+		authorized.GET("/inspection/template", controllers.GetActiveChecklistTemplate)
+		authorized.GET("/inspection/templates/:id", controllers.GetChecklistTemplate)
+		authorized.POST("/inspection/sync",
+			utils.RequirePermission(models.PermInspectionCreate), controllers.SyncInspections)
+
+		// 点检记录草稿接口，提交后转为正式记录
+		drafts := authorized.Group("/inspection/drafts", utils.RequirePermission(models.PermInspectionCreate))
+		drafts.GET("", controllers.ListInspectionDrafts)
+		drafts.POST("", controllers.CreateInspectionDraft)
+		drafts.GET("/:id", controllers.GetInspectionDraft)
+		drafts.PUT("/:id", controllers.SaveInspectionDraft)
+		drafts.DELETE("/:id", controllers.DeleteInspectionDraft)
+		drafts.POST("/:id/submit", controllers.SubmitInspectionDraft)
+		authorized.GET("/inspection/:id/history",
+			utils.RequirePermission(models.PermInspectionRead), controllers.GetInspectionHistory)
+		authorized.GET("/inspection/:id/attachments",
+			utils.RequirePermission(models.PermInspectionRead), controllers.ListRecordAttachments)
+		authorized.POST("/inspection/:id/attachments",
+			utils.RequirePermission(models.PermImageUpload), controllers.UploadRecordAttachment)
 
 		// 获取当前登录用户的点检记录
 		authorized.GET("/user/inspections", controllers.GetUserInspections)
@@ -29,15 +59,132 @@ func SetupRouter() *gin.Engine {
 		// 用户个人信息相关接口
 		authorized.GET("/profile", controllers.GetUserProfile)
 		authorized.PUT("/profile", controllers.UpdateUserProfile)
+		authorized.PUT("/profile/password", controllers.ChangePassword)
+
+		// 当前用户可访问的单位
+		authorized.GET("/units", controllers.GetMyUnits)
+		authorized.GET("/warehouses", controllers.GetMyWarehouses)
+
+		// 整改工单接口，保管员处理分配给自己的工单
+		authorized.GET("/rectifications",
+			utils.RequirePermission(models.PermInspectionRead), controllers.ListRectifications)
+		authorized.GET("/rectifications/:id",
+			utils.RequirePermission(models.PermInspectionRead), controllers.GetRectification)
+		authorized.POST("/rectifications/:id/start",
+			utils.RequirePermission(models.PermInspectionRead), controllers.StartRectification)
+		authorized.POST("/rectifications/:id/fix",
+			utils.RequirePermission(models.PermInspectionRead), controllers.FixRectification)
+
+		// 当前用户的点检任务
+		authorized.GET("/tasks/today", controllers.GetMyTasks)
+
+		// 扫描挡粮门二维码
+		authorized.POST("/qr/verify", controllers.VerifyDoorQR)
+
+		// 微信绑定相关接口
+		authorized.POST("/wx/bind", controllers.BindWechat)
+		authorized.DELETE("/wx/bind", controllers.UnbindWechat)
 
 		// 图片上传接口
-		authorized.POST("/upload/image", controllers.UploadImage)
+		authorized.POST("/upload/image",
+			utils.RequirePermission(models.PermImageUpload), controllers.UploadImage)
 
 		// 导出点检记录
-		authorized.POST("/export-inspection", controllers.ExportInspection)
+		authorized.POST("/export-inspection",
+			utils.RequirePermission(models.PermExportRun), controllers.ExportInspection)
+	}
+
+	// 管理员路由组
+	admin := r.Group("/admin", utils.JWTAuthMiddleware())
+	{
+		// 用户管理接口
+		users := admin.Group("/users", utils.RequirePermission(models.PermUserManage))
+		users.GET("", controllers.ListUsers)
+		users.POST("", controllers.CreateUser)
+		users.GET("/:id", controllers.GetUser)
+		users.PUT("/:id", controllers.UpdateUser)
+		users.PUT("/:id/status", controllers.UpdateUserStatus)
+		users.PUT("/:id/password", controllers.ResetUserPassword)
+		users.DELETE("/:id", controllers.DeleteUser)
+		users.PUT("/:id/units", controllers.SetUserUnits)
+		users.POST("/:id/unlock", controllers.UnlockUserLogin)
+
+		// 角色与权限管理接口
+		roles := admin.Group("", utils.RequirePermission(models.PermRoleManage))
+		roles.GET("/roles", controllers.ListRoles)
+		roles.POST("/roles", controllers.CreateRole)
+		roles.PUT("/roles/:id", controllers.UpdateRole)
+		roles.DELETE("/roles/:id", controllers.DeleteRole)
+		roles.GET("/permissions", controllers.ListPermissions)
+
+		// 单位管理接口
+		units := admin.Group("/units", utils.RequirePermission(models.PermUnitManage))
+		units.GET("", controllers.ListUnits)
+		units.POST("", controllers.CreateUnit)
+		units.PUT("/:id", controllers.UpdateUnit)
+		units.DELETE("/:id", controllers.DeleteUnit)
+
+		// 仓房和挡粮门主数据管理接口
+		warehouses := admin.Group("", utils.RequirePermission(models.PermWarehouseManage))
+		warehouses.GET("/warehouses", controllers.ListWarehouses)
+		warehouses.POST("/warehouses", controllers.CreateWarehouse)
+		warehouses.PUT("/warehouses/:id", controllers.UpdateWarehouse)
+		warehouses.DELETE("/warehouses/:id", controllers.DeleteWarehouse)
+		warehouses.POST("/warehouses/:id/doors", controllers.CreateGrainDoor)
+		warehouses.PUT("/doors/:id", controllers.UpdateGrainDoor)
+		warehouses.DELETE("/doors/:id", controllers.DeleteGrainDoor)
+		warehouses.GET("/doors/:id/qr", controllers.GetDoorQRCode)
+		warehouses.POST("/doors/:id/qr/rotate", controllers.RotateDoorQR)
+		warehouses.GET("/qr-labels", controllers.PrintDoorQRLabels)
+
+		// 点检计划管理和逾期任务接口
+		plans := admin.Group("", utils.RequirePermission(models.PermPlanManage))
+		plans.GET("/plans", controllers.ListInspectionPlans)
+		plans.POST("/plans", controllers.CreateInspectionPlan)
+		plans.PUT("/plans/:id", controllers.UpdateInspectionPlan)
+		plans.DELETE("/plans/:id", controllers.DeleteInspectionPlan)
+		plans.GET("/tasks/overdue", controllers.ListOverdueTasks)
+
+		// 整改工单分派和复查接口
+		rectifications := admin.Group("/rectifications", utils.RequirePermission(models.PermRectificationManage))
+		rectifications.PUT("/:id", controllers.AssignRectification)
+		rectifications.POST("/:id/verify", controllers.VerifyRectification)
+		rectifications.POST("/:id/reject", controllers.RejectRectification)
+
+		// 点检记录审核接口
+		reviews := admin.Group("/reviews", utils.RequirePermission(models.PermInspectionReview))
+		reviews.GET("", controllers.ListReviewQueue)
+		reviews.POST("/approve", controllers.BulkApproveInspections)
+		reviews.POST("/:id/approve", controllers.ApproveInspection)
+		reviews.POST("/:id/reject", controllers.RejectInspection)
+
+		// 点检模板版本管理接口
+		checklists := admin.Group("/checklist-templates", utils.RequirePermission(models.PermChecklistManage))
+		checklists.GET("", controllers.ListChecklistTemplates)
+		checklists.POST("", controllers.CreateChecklistTemplate)
+		checklists.GET("/:id", controllers.GetChecklistTemplate)
+		checklists.POST("/:id/activate", controllers.ActivateChecklistTemplate)
+
+		// 点检记录回退到历史版本
+		admin.POST("/inspections/:id/revert",
+			utils.RequirePermission(models.PermInspectionRevert), controllers.RevertInspection)
+
+		// 回收站接口
+		recycle := admin.Group("/recycle-bin", utils.RequirePermission(models.PermRecycleManage))
+		recycle.GET("", controllers.ListRecycleBin)
+		recycle.POST("/:id/restore", controllers.RestoreRecord)
+		recycle.DELETE("/:id", controllers.PurgeRecord)
+		recycle.DELETE("", controllers.PurgeExpiredRecords)
+
+		// 登录审计与锁定管理接口
+		admin.GET("/login-audit", utils.RequirePermission(models.PermAuditRead), controllers.ListLoginAttempts)
+		admin.GET("/login-locks", utils.RequirePermission(models.PermAuditRead), controllers.ListLoginLocks)
+		admin.DELETE("/login-locks/:id", utils.RequirePermission(models.PermUserManage), controllers.UnlockLogin)
 	}
 
-	r.Static("/images", "./uploads/images")
+	// 上传图片访问，size=thumb 或 size=medium 返回缩略版本
+	r.GET("/images/*filepath", controllers.ServeImage)
+	r.HEAD("/images/*filepath", controllers.ServeImage)
 	r.Static("/exports", "./exports") // 添加这一行来提供导出文件的访问
 
 	return r
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+
+	"gorm.io/gorm"
+)
+
+// CreateAttachment 登记上传的图片附件
+func CreateAttachment(attachment *models.Attachment) error {
+	return database.DB.Create(attachment).Error
+}
+
+// DeleteUnlinkedAttachments 删除尚未关联点检记录的附件登记，已关联的附件不会被删除
+func DeleteUnlinkedAttachments(ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return database.DB.Where("id IN ? AND record_id IS NULL", ids).Delete(&models.Attachment{}).Error
+}
+
+// GetRecordAttachments 获取点检记录的图片附件，field 非空时只返回该点检项的图片
+func GetRecordAttachments(recordID int, field *string) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	query := database.DB.Preload("Uploader").Where("record_id = ?", recordID)
+	if field != nil {
+		query = query.Where("field = ?", *field)
+	}
+	if err := query.Order("id ASC").Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// linkRecordAttachments 按点检记录的图片列表关联附件
+// 已上传但未关联的附件关联到记录和点检项，只认领记录提交人或本次保存的用户 userID 上传的附件，
+// 其他人上传的或没有登记过的图片补登记为新附件，记录不再引用的附件解除关联
+func linkRecordAttachments(tx *gorm.DB, record *models.InspectionRecord, userID int) error {
+	images := record.ImageFields()
+
+	var linked []models.Attachment
+	if err := tx.Where("record_id = ?", record.ID).Find(&linked).Error; err != nil {
+		return err
+	}
+	for _, attachment := range linked {
+		field, ok := images[attachment.URL]
+		if !ok {
+			if err := tx.Model(&attachment).Update("record_id", nil).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if attachment.Field != field {
+			if err := tx.Model(&attachment).Update("field", field).Error; err != nil {
+				return err
+			}
+		}
+		delete(images, attachment.URL)
+	}
+
+	for url, field := range images {
+		var attachment models.Attachment
+		err := tx.Where("url = ? AND record_id IS NULL AND uploader_id IN ?", url, []int{record.UserID, userID}).
+			Order("id DESC").First(&attachment).Error
+		if err == nil {
+			if err := tx.Model(&attachment).Updates(map[string]interface{}{"record_id": record.ID, "field": field}).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		// 客户端引用了未经上传接口登记或他人上传的图片，只记录路径，上传人未知
+		recordID := record.ID
+		attachment = models.Attachment{RecordID: &recordID, Field: field, URL: url, ThumbURL: url, MediumURL: url}
+		if err := tx.Create(&attachment).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckAttachmentField 校验图片所属的点检项，必须是记录所用模板中已填写的点检项，为空表示整条记录的图片
+func CheckAttachmentField(record *models.InspectionRecord, field string) error {
+	if field == "" {
+		return nil
+	}
+	template, err := recordTemplate(database.DB, record)
+	if err != nil {
+		return err
+	}
+	if _, ok := template.FindItem(field); !ok {
+		return &FieldError{Field: "field", Message: "unknown check item"}
+	}
+	answers, err := record.ChecklistAnswers()
+	if err != nil {
+		return err
+	}
+	if codes, _ := answers[field].Codes(); len(codes) == 0 {
+		return &FieldError{Field: "field", Message: "check item has not been answered"}
+	}
+	return nil
+}
+
+// AddRecordAttachment 将新上传的图片追加到点检记录，field 为空时作为整条记录的图片
+// 追加图片视为修改记录，写入版本历史，已审核的记录需要重新审核
+func AddRecordAttachment(record *models.InspectionRecord, attachment *models.Attachment, userID int) (*models.InspectionRecord, error) {
+	if err := CheckAttachmentField(record, attachment.Field); err != nil {
+		return nil, err
+	}
+	if attachment.Field == "" {
+		var images []string
+		_ = json.Unmarshal(record.Images, &images)
+		images = append(images, attachment.URL)
+		record.Images, _ = json.Marshal(images)
+	} else {
+		answers, err := record.ChecklistAnswers()
+		if err != nil {
+			return nil, err
+		}
+		answer := answers[attachment.Field]
+		answer.Images = append(answer.Images, attachment.URL)
+		answers[attachment.Field] = answer
+		record.Answers, _ = json.Marshal(answers)
+	}
+	markResubmitted(record)
+	saved, err := saveRecordWithRevision(record, models.RevisionActionUpdate, userID)
+	if err != nil {
+		return nil, err
+	}
+	attachment.RecordID = &saved.ID
+	return saved, nil
+}
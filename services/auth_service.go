@@ -2,26 +2,123 @@ package services
 
 import (
 	"errors"
+	"log"
 
 	"DLM_backend/database"
 	"DLM_backend/models"
 	"DLM_backend/utils"
 )
 
-// AuthenticateUser 校验用户凭据，并生成 JWT 令牌
-func AuthenticateUser(username, password, role string) (string, error) {
+// ErrAccountDisabled 账号已被管理员停用
+var ErrAccountDisabled = errors.New("account disabled")
+
+// ErrInvalidCredentials 用户名、密码或角色错误
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// AuthenticateUser 校验用户凭据，并签发访问令牌和刷新令牌
+// 连续失败会按用户名和IP锁定，每次尝试都会写入登录审计日志
+func AuthenticateUser(username, password, role string, client ClientInfo) (*TokenPair, error) {
+	attempt := models.LoginAttempt{
+		Username:  username,
+		Role:      role,
+		Method:    "password",
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+
+	if err := CheckLoginAllowed(username, client.IP); err != nil {
+		attempt.Reason = "locked"
+		WriteLoginAudit(&attempt)
+		return nil, err
+	}
+
+	tokens, err := authenticatePassword(username, password, role, client)
+	switch {
+	case err == nil:
+		attempt.Success = true
+		RecordLoginSuccess(username, client.IP)
+	case errors.Is(err, ErrInvalidCredentials):
+		attempt.Reason = "invalid credentials"
+		RecordLoginFailure(username, client.IP)
+	default:
+		attempt.Reason = err.Error()
+	}
+	WriteLoginAudit(&attempt)
+	return tokens, err
+}
+
+// authenticatePassword 校验用户名、密码和角色
+func authenticatePassword(username, password, role string, client ClientInfo) (*TokenPair, error) {
 	var user models.User
 
-	// 查询指定用户名、密码和角色的用户
-	if err := database.DB.Where("username = ? AND password = ? AND role = ?",
-		username, password, role).First(&user).Error; err != nil {
-		return "", errors.New("invalid credentials")
+	// 查询指定用户名和角色的用户，密码在应用层校验
+	if err := database.DB.Where("username = ? AND role = ?", username, role).First(&user).Error; err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	ok, needsRehash := utils.CheckPassword(user.Password, password)
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	// 密码正确但账号已停用
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+
+	// 历史明文密码在首次登录成功后升级为哈希存储
+	if needsRehash {
+		if err := upgradePasswordHash(&user, password); err != nil {
+			// 升级失败不影响本次登录，下次登录会再次尝试
+			log.Printf("failed to upgrade password hash for user %d: %v", user.ID, err)
+		}
+	}
+
+	return IssueTokens(&user, client)
+}
+
+// ErrWrongPassword 原密码错误
+var ErrWrongPassword = errors.New("current password is incorrect")
+
+// ChangePassword 校验原密码后修改密码，并使该用户此前签发的令牌全部失效
+// 返回基于新令牌版本签发的令牌对，供当前设备继续使用
+func ChangePassword(user *models.User, oldPassword, newPassword string, client ClientInfo) (*TokenPair, error) {
+	if ok, _ := utils.CheckPassword(user.Password, oldPassword); !ok {
+		return nil, ErrWrongPassword
+	}
+	if err := utils.ValidatePasswordStrength(newPassword); err != nil {
+		return nil, err
+	}
+
+	if err := setPassword(user, newPassword, user.ID); err != nil {
+		return nil, err
 	}
+	return IssueTokens(user, client)
+}
+
+// setPassword 保存新密码的哈希并递增令牌版本号，旧的访问令牌和刷新令牌随之失效
+// actorID 为执行修改的用户（本人修改或管理员重置）
+func setPassword(user *models.User, password string, actorID int) error {
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.Password = hashed
+	user.TokenVersion++
+	user.SetUpdater(actorID)
+	return database.DB.Model(user).Updates(map[string]interface{}{
+		"password":      user.Password,
+		"token_version": user.TokenVersion,
+		"updated_by":    user.UpdatedBy,
+	}).Error
+}
 
-	// 生成包含用户名和角色的令牌
-	token, err := utils.GenerateToken(user.Username, user.Role)
+// upgradePasswordHash 将用户的明文密码替换为哈希值
+func upgradePasswordHash(user *models.User, password string) error {
+	hashed, err := utils.HashPassword(password)
 	if err != nil {
-		return "", err
+		return err
 	}
-	return token, nil
+	// 系统自动升级，不更新修改时间和修改人
+	return database.DB.Model(user).UpdateColumn("password", hashed).Error
 }
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrTemplateNotFound 点检模板不存在
+var ErrTemplateNotFound = errors.New("checklist template not found")
+
+// ErrNoActiveTemplate 没有可用于新点检记录的模板
+var ErrNoActiveTemplate = errors.New("no active checklist template")
+
+// checklistFieldPattern 点检项标识只能由小写字母、数字和下划线组成
+var checklistFieldPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// GetActiveChecklistTemplate 获取新点检记录使用的模板
+func GetActiveChecklistTemplate() (*models.ChecklistTemplate, error) {
+	return activeChecklistTemplate(database.DB)
+}
+
+// activeChecklistTemplate 在指定连接中获取当前启用的模板
+func activeChecklistTemplate(db *gorm.DB) (*models.ChecklistTemplate, error) {
+	var template models.ChecklistTemplate
+	if err := db.Where("active = ?", true).Order("id DESC").First(&template).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoActiveTemplate
+		}
+		return nil, err
+	}
+	return &template, nil
+}
+
+// GetChecklistTemplateByID 根据ID获取点检模板版本
+func GetChecklistTemplateByID(id int) (*models.ChecklistTemplate, error) {
+	return getChecklistTemplate(database.DB, id)
+}
+
+// getChecklistTemplate 在指定连接中获取点检模板版本
+func getChecklistTemplate(db *gorm.DB, id int) (*models.ChecklistTemplate, error) {
+	var template models.ChecklistTemplate
+	if err := db.First(&template, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTemplateNotFound
+		}
+		return nil, err
+	}
+	return &template, nil
+}
+
+// GetChecklistTemplates 获取所有点检模板版本，按编码和版本排序，code 非空时只返回该模板的各版本
+func GetChecklistTemplates(code string) ([]models.ChecklistTemplate, error) {
+	var templates []models.ChecklistTemplate
+	query := database.DB.Order("code ASC, version DESC")
+	if code != "" {
+		query = query.Where("code = ?", code)
+	}
+	if err := query.Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// GetChecklistTemplatesByIDs 批量获取点检模板版本，以ID为键
+func GetChecklistTemplatesByIDs(ids []int) (map[int]*models.ChecklistTemplate, error) {
+	templates := make(map[int]*models.ChecklistTemplate, len(ids))
+	if len(ids) == 0 {
+		return templates, nil
+	}
+	var list []models.ChecklistTemplate
+	if err := database.DB.Where("id IN ?", ids).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	for i := range list {
+		templates[list[i].ID] = &list[i]
+	}
+	return templates, nil
+}
+
+// validateChecklistItems 校验模板中的点检项定义
+func validateChecklistItems(items []models.ChecklistItem) error {
+	if len(items) == 0 {
+		return &FieldError{Field: "items", Message: "at least one check item is required"}
+	}
+	fields := make(map[string]bool, len(items))
+	for i, item := range items {
+		prefix := fmt.Sprintf("items[%d]", i)
+		if !checklistFieldPattern.MatchString(item.Field) {
+			return &FieldError{Field: prefix + ".field", Message: "must be lowercase letters, digits or underscores and start with a letter"}
+		}
+		if fields[item.Field] {
+			return &FieldError{Field: prefix + ".field", Message: fmt.Sprintf("duplicate check item %q", item.Field)}
+		}
+		fields[item.Field] = true
+		if strings.TrimSpace(item.Label) == "" {
+			return &FieldError{Field: prefix + ".label", Message: "is required"}
+		}
+		if item.MinPhotos < 0 || item.MinPhotos > 20 {
+			return &FieldError{Field: prefix + ".min_photos", Message: "must be between 0 and 20"}
+		}
+		if len(item.Options) == 0 {
+			return &FieldError{Field: prefix + ".options", Message: "at least one option is required"}
+		}
+		codes := make(map[string]bool, len(item.Options))
+		for j, option := range item.Options {
+			optionPrefix := fmt.Sprintf("%s.options[%d]", prefix, j)
+			if option.Code == "" || len(option.Code) > 64 {
+				return &FieldError{Field: optionPrefix + ".code", Message: "is required and at most 64 characters"}
+			}
+			if codes[option.Code] {
+				return &FieldError{Field: optionPrefix + ".code", Message: fmt.Sprintf("duplicate option %q", option.Code)}
+			}
+			codes[option.Code] = true
+			if strings.TrimSpace(option.Label) == "" {
+				return &FieldError{Field: optionPrefix + ".label", Message: "is required"}
+			}
+		}
+		if item.Normal != "" {
+			option, ok := item.FindOption(item.Normal)
+			if !ok {
+				return &FieldError{Field: prefix + ".normal", Message: "must be one of the options"}
+			}
+			if option.Abnormal {
+				return &FieldError{Field: prefix + ".normal", Message: "cannot be an abnormal option"}
+			}
+		}
+	}
+	return nil
+}
+
+// CreateChecklistTemplate 发布点检模板的新版本，版本号在同一编码的已有版本上递增
+// activate 为 true 时新版本立即用于新点检记录
+func CreateChecklistTemplate(template *models.ChecklistTemplate, activate bool, userID int) (*models.ChecklistTemplate, error) {
+	template.Code = strings.TrimSpace(template.Code)
+	template.Name = strings.TrimSpace(template.Name)
+	if !checklistFieldPattern.MatchString(template.Code) {
+		return nil, &FieldError{Field: "code", Message: "must be lowercase letters, digits or underscores and start with a letter"}
+	}
+	if template.Name == "" {
+		return nil, &FieldError{Field: "name", Message: "is required"}
+	}
+	if err := validateChecklistItems(template.Items); err != nil {
+		return nil, err
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var latest int
+		if err := tx.Model(&models.ChecklistTemplate{}).Where("code = ?", template.Code).
+			Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+			return err
+		}
+		template.ID = 0
+		template.Version = latest + 1
+		template.Active = false
+		template.SetCreator(userID)
+		if err := tx.Create(template).Error; err != nil {
+			return err
+		}
+		if activate {
+			return activateChecklistTemplate(tx, template, userID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// ActivateChecklistTemplate 将指定版本设为新点检记录使用的模板，其他版本同时停用
+// 已提交的记录仍按各自的模板版本校验，不受影响
+func ActivateChecklistTemplate(id int, userID int) (*models.ChecklistTemplate, error) {
+	var template *models.ChecklistTemplate
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if template, err = getChecklistTemplate(tx, id); err != nil {
+			return err
+		}
+		return activateChecklistTemplate(tx, template, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// activateChecklistTemplate 在事务中切换启用的模板
+func activateChecklistTemplate(tx *gorm.DB, template *models.ChecklistTemplate, userID int) error {
+	if err := tx.Model(&models.ChecklistTemplate{}).Where("active = ? AND id <> ?", true, template.ID).
+		Updates(map[string]interface{}{"active": false, "updated_by": userID}).Error; err != nil {
+		return err
+	}
+	template.Active = true
+	template.SetUpdater(userID)
+	return tx.Model(template).Updates(map[string]interface{}{"active": true, "updated_by": userID}).Error
+}
+
+// recordTemplate 获取点检记录使用的模板版本，记录尚未指定模板时使用当前启用的模板
+func recordTemplate(db *gorm.DB, record *models.InspectionRecord) (*models.ChecklistTemplate, error) {
+	if record.TemplateID != nil {
+		template, err := getChecklistTemplate(db, *record.TemplateID)
+		if errors.Is(err, ErrTemplateNotFound) {
+			return nil, &FieldError{Field: "template_id", Message: "checklist template not found"}
+		}
+		return template, err
+	}
+	return activeChecklistTemplate(db)
+}
+
+// ExportChecklistItems 合并导出记录使用的各模板版本的点检项，作为导出的点检项列
+// 按模板版本ID顺序排列，标识相同的点检项只保留最早出现的一列
+func ExportChecklistItems(templates map[int]*models.ChecklistTemplate) []models.ChecklistItem {
+	ids := make([]int, 0, len(templates))
+	for id := range templates {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	var items []models.ChecklistItem
+	seen := map[string]bool{}
+	for _, id := range ids {
+		for _, item := range templates[id].Items {
+			if seen[item.Field] {
+				continue
+			}
+			seen[item.Field] = true
+			items = append(items, item)
+		}
+	}
+	return items
+}
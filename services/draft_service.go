@@ -0,0 +1,107 @@
+package services
+
+import (
+	"errors"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrDraftNotFound 草稿不存在或不属于当前用户
+var ErrDraftNotFound = errors.New("draft not found")
+
+// ErrDraftVersionConflict 草稿已在其他设备上保存过，客户端的版本已过期
+var ErrDraftVersionConflict = errors.New("draft has been saved from another device, reload it before saving")
+
+// GetUserDrafts 获取用户的全部草稿，最近保存的排在前面
+func GetUserDrafts(userID int) ([]models.InspectionDraft, error) {
+	var drafts []models.InspectionDraft
+	if err := database.DB.Where("user_id = ?", userID).Order("updated_at DESC").Find(&drafts).Error; err != nil {
+		return nil, err
+	}
+	return drafts, nil
+}
+
+// GetUserDraft 获取用户的一份草稿
+func GetUserDraft(id, userID int) (*models.InspectionDraft, error) {
+	var draft models.InspectionDraft
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&draft).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDraftNotFound
+		}
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// CreateDraft 新建草稿
+func CreateDraft(draft *models.InspectionDraft) (*models.InspectionDraft, error) {
+	draft.Version = 1
+	draft.SetCreator(draft.UserID)
+	if err := database.DB.Create(draft).Error; err != nil {
+		return nil, err
+	}
+	return draft, nil
+}
+
+// SaveDraft 保存草稿内容，expectedVersion 大于 0 时只有与当前版本一致才保存，避免覆盖其他设备保存的内容
+func SaveDraft(draft *models.InspectionDraft, expectedVersion int) (*models.InspectionDraft, error) {
+	query := database.DB.Model(&models.InspectionDraft{}).Where("id = ? AND user_id = ?", draft.ID, draft.UserID)
+	if expectedVersion > 0 {
+		query = query.Where("version = ?", expectedVersion)
+	}
+	result := query.Updates(map[string]interface{}{
+		"data":                draft.Data,
+		"unit":                draft.Unit,
+		"warehouse_number":    draft.WarehouseNumber,
+		"grain_door_position": draft.GrainDoorPosition,
+		"version":             gorm.Expr("version + 1"),
+		"updated_by":          draft.UserID,
+	})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		if _, err := GetUserDraft(draft.ID, draft.UserID); err != nil {
+			return nil, err
+		}
+		return nil, ErrDraftVersionConflict
+	}
+	return GetUserDraft(draft.ID, draft.UserID)
+}
+
+// DeleteDraft 删除用户的草稿
+func DeleteDraft(id, userID int) error {
+	result := database.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.InspectionDraft{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDraftNotFound
+	}
+	return nil
+}
+
+// SubmitDraft 将草稿转为正式点检记录，记录经过与新增接口相同的校验，保存成功后删除草稿
+func SubmitDraft(draft *models.InspectionDraft, record *models.InspectionRecord) (*models.InspectionRecord, error) {
+	if err := ValidateRecordChecklist(record); err != nil {
+		return nil, err
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// 草稿已被删除或已从其他设备提交时不再重复生成记录
+		result := tx.Where("id = ? AND user_id = ?", draft.ID, draft.UserID).Delete(&models.InspectionDraft{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrDraftNotFound
+		}
+		return createRecordTx(tx, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
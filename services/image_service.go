@@ -0,0 +1,349 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png" // 注册 PNG 解码器
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // 注册 WebP 解码器
+)
+
+// 上传图片被拒绝的错误码，客户端据此提示用户
+const (
+	ImageErrEmpty              = "empty_file"           // 文件为空
+	ImageErrTooLarge           = "file_too_large"       // 文件超过大小限制
+	ImageErrUnsupportedType    = "unsupported_type"     // 文件内容不是允许的图片格式
+	ImageErrInvalid            = "invalid_image"        // 图片文件已损坏，无法读取尺寸
+	ImageErrDimensionsTooLarge = "dimensions_too_large" // 图片宽高或像素数超过限制
+)
+
+// ImageRejectedError 上传的图片未通过校验
+type ImageRejectedError struct {
+	Code    string // 错误码
+	Message string // 错误说明
+}
+
+func (e *ImageRejectedError) Error() string {
+	return e.Message
+}
+
+// ImagePolicy 上传图片的限制
+type ImagePolicy struct {
+	MaxBytes  int64 // 文件最大字节数
+	MaxWidth  int   // 最大宽度（像素）
+	MaxHeight int   // 最大高度（像素）
+	MaxPixels int   // 最大像素数（宽×高）
+
+	ThumbSize  int // 缩略图长边像素，0 表示不生成
+	MediumSize int // 中图长边像素，0 表示不生成
+}
+
+// imagePolicy 上传图片的限制，可以通过 SetImagePolicy 覆盖
+var imagePolicy = ImagePolicy{
+	MaxBytes:  10 << 20,
+	MaxWidth:  8192,
+	MaxHeight: 8192,
+	MaxPixels: 40000000,
+
+	ThumbSize:  320,
+	MediumSize: 1280,
+}
+
+// SetImagePolicy 设置上传图片的限制
+func SetImagePolicy(policy ImagePolicy) {
+	imagePolicy = policy
+}
+
+// GetImagePolicy 获取上传图片的限制
+func GetImagePolicy() ImagePolicy {
+	return imagePolicy
+}
+
+// ImageInfo 通过校验的图片信息
+type ImageInfo struct {
+	ContentType string // 按文件内容识别的类型
+	Ext         string // 保存时使用的扩展名
+	Width       int    // 宽度（像素）
+	Height      int    // 高度（像素）
+}
+
+// imageFormat 允许上传的图片格式
+type imageFormat struct {
+	contentType string
+	ext         string
+	match       func(header []byte) bool
+}
+
+// imageFormats 按文件头识别的图片格式，只允许 JPEG、PNG 和 WebP
+var imageFormats = []imageFormat{
+	{"image/jpeg", ".jpg", func(h []byte) bool { return bytes.HasPrefix(h, []byte{0xFF, 0xD8, 0xFF}) }},
+	{"image/png", ".png", func(h []byte) bool { return bytes.HasPrefix(h, []byte("\x89PNG\r\n\x1a\n")) }},
+	{"image/webp", ".webp", func(h []byte) bool {
+		return len(h) >= 12 && bytes.Equal(h[:4], []byte("RIFF")) && bytes.Equal(h[8:12], []byte("WEBP"))
+	}},
+}
+
+// matchImageFormat 按文件头识别允许的图片格式，不是允许的格式时返回 nil
+func matchImageFormat(header []byte) *imageFormat {
+	for i := range imageFormats {
+		if imageFormats[i].match(header) {
+			return &imageFormats[i]
+		}
+	}
+	return nil
+}
+
+// ImageContentType 按文件头识别允许的图片格式，返回内容类型和对应的扩展名，不是允许的格式时返回空字符串
+func ImageContentType(header []byte) (contentType, ext string) {
+	if format := matchImageFormat(header); format != nil {
+		return format.contentType, format.ext
+	}
+	return "", ""
+}
+
+// ValidateImage 按文件内容校验上传的图片，size 为文件大小
+// 类型按文件头识别而不信任客户端提供的文件名和类型，尺寸只读取图片头部而不解码整张图片
+func ValidateImage(r io.ReadSeeker, size int64) (*ImageInfo, error) {
+	if size <= 0 {
+		return nil, &ImageRejectedError{Code: ImageErrEmpty, Message: "file is empty"}
+	}
+	if imagePolicy.MaxBytes > 0 && size > imagePolicy.MaxBytes {
+		return nil, &ImageRejectedError{Code: ImageErrTooLarge,
+			Message: fmt.Sprintf("file exceeds the maximum size of %d bytes", imagePolicy.MaxBytes)}
+	}
+
+	header := make([]byte, 12)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	format := matchImageFormat(header[:n])
+	if format == nil {
+		return nil, &ImageRejectedError{Code: ImageErrUnsupportedType, Message: "only JPEG, PNG and WebP images are allowed"}
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	config, _, err := image.DecodeConfig(r)
+	if err != nil || config.Width <= 0 || config.Height <= 0 {
+		return nil, &ImageRejectedError{Code: ImageErrInvalid, Message: "image is corrupted or cannot be read"}
+	}
+	if (imagePolicy.MaxWidth > 0 && config.Width > imagePolicy.MaxWidth) ||
+		(imagePolicy.MaxHeight > 0 && config.Height > imagePolicy.MaxHeight) ||
+		(imagePolicy.MaxPixels > 0 && config.Width*config.Height > imagePolicy.MaxPixels) {
+		return nil, &ImageRejectedError{Code: ImageErrDimensionsTooLarge,
+			Message: fmt.Sprintf("image dimensions %dx%d exceed the limit of %dx%d and %d pixels",
+				config.Width, config.Height, imagePolicy.MaxWidth, imagePolicy.MaxHeight, imagePolicy.MaxPixels)}
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return &ImageInfo{
+		ContentType: format.contentType,
+		Ext:         format.ext,
+		Width:       config.Width,
+		Height:      config.Height,
+	}, nil
+}
+
+// 上传图片的缩略版本，列表等场景按需加载以节省流量
+const (
+	ImageVariantThumb  = "thumb"  // 缩略图
+	ImageVariantMedium = "medium" // 中图
+)
+
+// ImageVariants 生成的缩略版本
+var ImageVariants = []string{ImageVariantThumb, ImageVariantMedium}
+
+// imageVariantQuality 缩略版本的 JPEG 压缩质量
+const imageVariantQuality = 80
+
+// ImageVariantFilename 返回图片缩略版本的文件名，缩略版本统一保存为 JPEG
+func ImageVariantFilename(filename, variant string) string {
+	return strings.TrimSuffix(filename, path.Ext(filename)) + "_" + variant + ".jpg"
+}
+
+// imageVariantSize 返回缩略版本的长边像素
+func imageVariantSize(variant string) int {
+	switch variant {
+	case ImageVariantThumb:
+		return imagePolicy.ThumbSize
+	case ImageVariantMedium:
+		return imagePolicy.MediumSize
+	}
+	return 0
+}
+
+// GenerateImageVariants 为保存在 dir 中的原图生成缩略版本，返回各版本的文件名
+// 原图长边不超过版本尺寸时不生成该版本，访问时直接使用原图；按 JPEG 的 EXIF 方向信息旋转，透明背景填充为白色
+func GenerateImageVariants(dir, filename string) (map[string]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, filename))
+	if err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, &ImageRejectedError{Code: ImageErrInvalid, Message: "image is corrupted or cannot be read"}
+	}
+	orientation := jpegOrientation(data)
+
+	variants := make(map[string]string, len(ImageVariants))
+	for _, variant := range ImageVariants {
+		size := imageVariantSize(variant)
+		bounds := src.Bounds()
+		if size <= 0 || (bounds.Dx() <= size && bounds.Dy() <= size) {
+			continue
+		}
+		name := ImageVariantFilename(filename, variant)
+		if err := writeJPEG(filepath.Join(dir, name), orientImage(resizeImage(src, size), orientation)); err != nil {
+			RemoveImageVariants(dir, filename)
+			return nil, err
+		}
+		variants[variant] = name
+	}
+	return variants, nil
+}
+
+// RemoveImageVariants 删除原图的所有缩略版本
+func RemoveImageVariants(dir, filename string) {
+	for _, variant := range ImageVariants {
+		_ = os.Remove(filepath.Join(dir, ImageVariantFilename(filename, variant)))
+	}
+}
+
+// resizeImage 按比例缩小图片，使长边等于 size
+func resizeImage(src image.Image, size int) *image.RGBA {
+	bounds := src.Bounds()
+	width, height := size, size
+	if bounds.Dx() >= bounds.Dy() {
+		height = max(1, bounds.Dy()*size/bounds.Dx())
+	} else {
+		width = max(1, bounds.Dx()*size/bounds.Dy())
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.BiLinear.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+	return dst
+}
+
+// orientImage 按 EXIF 方向值（1-8）旋转或翻转图片，使其按拍摄方向显示
+func orientImage(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 水平翻转
+				dx, dy = w-1-x, y
+			case 3: // 旋转 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				dx, dy = x, h-1-y
+			case 5: // 沿主对角线翻转
+				dx, dy = y, x
+			case 6: // 顺时针旋转 90°
+				dx, dy = h-1-y, x
+			case 7: // 沿副对角线翻转
+				dx, dy = h-1-y, w-1-x
+			case 8: // 逆时针旋转 90°
+				dx, dy = y, w-1-x
+			}
+			dst.SetRGBA(dx, dy, src.RGBAAt(x, y))
+		}
+	}
+	return dst
+}
+
+// jpegOrientation 读取 JPEG 文件 EXIF 中的方向值，没有方向信息时返回 1
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			// 已到图像数据，EXIF 只会出现在之前
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation 在 EXIF 的 TIFF 结构中查找方向标签（0x0112）
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if value := int(order.Uint16(tiff[entry+8:])); value >= 1 && value <= 8 {
+				return value
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// writeJPEG 将图片以 JPEG 格式写入文件
+func writeJPEG(name string, img image.Image) error {
+	out, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(out, img, &jpeg.Options{Quality: imageVariantQuality}); err != nil {
+		out.Close()
+		_ = os.Remove(name)
+		return err
+	}
+	return out.Close()
+}
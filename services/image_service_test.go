@@ -0,0 +1,149 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// encodeTestImage 生成指定尺寸的 PNG 或 JPEG 图片
+func encodeTestImage(t *testing.T, format string, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 0x80, A: 0xFF})
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	if format == "png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("encode %s: %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+// testWebP 1×1 的无损 WebP 图片
+var testWebP = []byte("RIFF\x1a\x00\x00\x00WEBPVP8L\x0d\x00\x00\x00\x2f\x00\x00\x00\x10\x07\x10\x11\x11\x88\x88\xfe\x07\x00")
+
+func TestValidateImage(t *testing.T) {
+	previous := GetImagePolicy()
+	t.Cleanup(func() { SetImagePolicy(previous) })
+	SetImagePolicy(ImagePolicy{MaxBytes: 64 << 10, MaxWidth: 100, MaxHeight: 80, MaxPixels: 6000})
+
+	pngData := encodeTestImage(t, "png", 40, 30)
+	jpegData := encodeTestImage(t, "jpeg", 60, 50)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		size     int64 // 为 0 时使用 data 的长度
+		wantCode string
+		wantType string
+		wantExt  string
+		wantW    int
+		wantH    int
+	}{
+		{name: "png", data: pngData, wantType: "image/png", wantExt: ".png", wantW: 40, wantH: 30},
+		{name: "jpeg", data: jpegData, wantType: "image/jpeg", wantExt: ".jpg", wantW: 60, wantH: 50},
+		{name: "webp", data: testWebP, wantType: "image/webp", wantExt: ".webp", wantW: 1, wantH: 1},
+		{name: "empty", data: nil, wantCode: ImageErrEmpty},
+		{name: "too large", data: pngData, size: 64<<10 + 1, wantCode: ImageErrTooLarge},
+		{name: "text file", data: []byte("hello, world"), wantCode: ImageErrUnsupportedType},
+		{name: "executable", data: []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00\x00\x00"), wantCode: ImageErrUnsupportedType},
+		{name: "gif", data: []byte("GIF89a\x01\x00\x01\x00\x00\x00"), wantCode: ImageErrUnsupportedType},
+		{name: "short header", data: []byte{0xFF, 0xD8}, wantCode: ImageErrUnsupportedType},
+		{name: "truncated png", data: pngData[:16], wantCode: ImageErrInvalid},
+		{name: "corrupted jpeg", data: []byte("\xFF\xD8\xFF\xE0garbage-after-header"), wantCode: ImageErrInvalid},
+		{name: "too wide", data: encodeTestImage(t, "png", 101, 10), wantCode: ImageErrDimensionsTooLarge},
+		{name: "too tall", data: encodeTestImage(t, "png", 10, 81), wantCode: ImageErrDimensionsTooLarge},
+		{name: "too many pixels", data: encodeTestImage(t, "png", 100, 61), wantCode: ImageErrDimensionsTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size := tt.size
+			if size == 0 {
+				size = int64(len(tt.data))
+			}
+			r := bytes.NewReader(tt.data)
+			info, err := ValidateImage(r, size)
+			if tt.wantCode != "" {
+				var rejected *ImageRejectedError
+				if !errors.As(err, &rejected) {
+					t.Fatalf("error = %v, want code %s", err, tt.wantCode)
+				}
+				if rejected.Code != tt.wantCode {
+					t.Fatalf("code = %s, want %s", rejected.Code, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.ContentType != tt.wantType || info.Ext != tt.wantExt || info.Width != tt.wantW || info.Height != tt.wantH {
+				t.Fatalf("info = %+v, want %s %s %dx%d", info, tt.wantType, tt.wantExt, tt.wantW, tt.wantH)
+			}
+			// 校验后读取位置回到开头，调用方可以直接保存文件
+			if offset, _ := r.Seek(0, io.SeekCurrent); offset != 0 {
+				t.Fatalf("reader offset = %d, want 0", offset)
+			}
+		})
+	}
+}
+
+// exifJPEG 生成只包含 EXIF 方向标签的 JPEG 文件头
+func exifJPEG(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 26)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], 0x0112)
+	order.PutUint16(tiff[12:], 3)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(data[4:], uint16(len(segment)+2))
+	data = append(data, segment...)
+	return append(data, 0xFF, 0xDA)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{name: "little endian", data: exifJPEG(binary.LittleEndian, 6), want: 6},
+		{name: "big endian", data: exifJPEG(binary.BigEndian, 8), want: 8},
+		{name: "out of range", data: exifJPEG(binary.LittleEndian, 9), want: 1},
+		{name: "no exif", data: encodeTestImage(t, "jpeg", 4, 4), want: 1},
+		{name: "not jpeg", data: encodeTestImage(t, "png", 4, 4), want: 1},
+		{name: "truncated", data: exifJPEG(binary.LittleEndian, 6)[:20], want: 1},
+		{name: "empty", data: nil, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Fatalf("orientation = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
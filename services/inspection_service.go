@@ -3,23 +3,115 @@ package services
 import (
 	"DLM_backend/database"
 	"DLM_backend/models"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
-// CreateInspectionRecord 新建点检记录
+// ErrRecordNotFound 点检记录不存在
+var ErrRecordNotFound = errors.New("record not found")
+
+// ErrRecordNotOwned 只能修改或删除本人提交的点检记录
+var ErrRecordNotOwned = errors.New("you can only modify your own records")
+
+// ErrEditWindowExpired 已超过允许修改的时间窗口
+var ErrEditWindowExpired = errors.New("record can no longer be modified, edit window has expired")
+
+// ErrRecordModified 保存时记录已被其他请求修改
+var ErrRecordModified = errors.New("record was modified by another request, reload it and retry")
+
+// recordEditWindow 保管员提交记录后可修改/删除的时间窗口，0 表示不限制
+var recordEditWindow = 24 * time.Hour
+
+// SetRecordEditWindow 设置保管员修改记录的时间窗口
+func SetRecordEditWindow(window time.Duration) {
+	recordEditWindow = window
+}
+
+// CreateInspectionRecord 新建点检记录，并记录第一个版本
 func CreateInspectionRecord(record *models.InspectionRecord) (*models.InspectionRecord, error) {
-	if err := database.DB.Create(record).Error; err != nil {
+	if err := ValidateRecordChecklist(record); err != nil {
+		return nil, err
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return createRecordTx(tx, record)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return record, nil
 }
 
-// GetInspectionRecords 获取所有点检记录（兼容原有API）
-func GetInspectionRecords() ([]models.InspectionRecord, error) {
+// createRecordTx 在事务中保存新点检记录，写入首个版本，关联图片附件并为异常项生成整改工单
+func createRecordTx(tx *gorm.DB, record *models.InspectionRecord) error {
+	record.SetCreator(record.UserID)
+	record.ReviewStatus = models.ReviewSubmitted
+	record.Version = 1
+	if err := tx.Create(record).Error; err != nil {
+		return err
+	}
+	if err := recordRevision(tx, record, models.RevisionActionCreate, record.UserID); err != nil {
+		return err
+	}
+	if err := linkRecordAttachments(tx, record, record.UserID); err != nil {
+		return err
+	}
+	return openRectificationTickets(tx, record)
+}
+
+// GetInspectionRecordByID 根据ID获取点检记录
+func GetInspectionRecordByID(id int) (*models.InspectionRecord, error) {
+	var record models.InspectionRecord
+	if err := database.DB.First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+// CheckRecordModifiable 校验用户能否修改或删除点检记录
+// canModifyAny 为 true 时（如管理员）不受归属和时间窗口限制；被驳回的记录提交人可随时修改后重新提交
+func CheckRecordModifiable(record *models.InspectionRecord, userID int, canModifyAny bool) error {
+	if canModifyAny {
+		return nil
+	}
+	if record.UserID != userID {
+		return ErrRecordNotOwned
+	}
+	if recordEditWindow > 0 && record.ReviewStatus != models.ReviewRejected {
+		// 历史记录没有提交时间，以检查时间代替
+		submittedAt := record.CreatedAt
+		if submittedAt.IsZero() {
+			submittedAt = record.InspectionTime
+		}
+		if time.Since(submittedAt) > recordEditWindow {
+			return ErrEditWindowExpired
+		}
+	}
+	return nil
+}
+
+// CheckRecordInScope 校验点检记录是否在用户可访问的单位范围内
+// scope 为 nil 表示不限制单位；不在范围内的记录按不存在处理，避免泄露其他单位的数据
+func CheckRecordInScope(record *models.InspectionRecord, scope []int) error {
+	if scope == nil {
+		return nil
+	}
+	if record.UnitID == nil || !containsID(scope, *record.UnitID) {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetInspectionRecords 获取所有满足过滤条件的点检记录（用于导出）
+func GetInspectionRecords(filters map[string]interface{}) ([]models.InspectionRecord, error) {
 	// 使用一个足够大的值获取所有数据
-	_, records, err := GetInspectionRecordsWithPagination(1, 1000)
+	_, records, err := GetInspectionRecordsWithFilters(1, 1000, filters)
 	return records, err
 }
 
@@ -44,6 +136,14 @@ func GetInspectionRecordsWithPagination(page, pageSize int) (int64, []models.Ins
 	return total, records, nil
 }
 
+// sortableRecordFields 点检记录列表允许排序的字段
+var sortableRecordFields = map[string]bool{
+	"id":              true,
+	"inspection_time": true,
+	"created_at":      true,
+	"updated_at":      true,
+}
+
 // GetInspectionRecordsWithFilters 获取带条件过滤的分页点检记录
 func GetInspectionRecordsWithFilters(page, pageSize int, filters map[string]interface{}) (int64, []models.InspectionRecord, error) {
 	var records []models.InspectionRecord
@@ -56,6 +156,27 @@ func GetInspectionRecordsWithFilters(page, pageSize int, filters map[string]inte
 		workingFilters[k] = v
 	}
 
+	// 限制在用户可访问的单位范围内
+	if scope, ok := workingFilters["unit_scope"].([]int); ok {
+		query = query.Where("unit_id IN ?", scope)
+		delete(workingFilters, "unit_scope")
+	}
+
+	// 按审核状态过滤，支持逗号分隔的多个状态
+	if reviewStatus, ok := workingFilters["review_status"].(string); ok {
+		if reviewStatus != "" {
+			query = query.Where("review_status IN ?", strings.Split(reviewStatus, ","))
+		}
+		delete(workingFilters, "review_status")
+	}
+
+	// 按仓房过滤，匹配该仓房下所有挡粮门的记录
+	if warehouseID, ok := workingFilters["warehouse_id"].(int); ok {
+		query = query.Where("grain_door_id IN (?)",
+			database.DB.Model(&models.GrainDoor{}).Select("id").Where("warehouse_id = ?", warehouseID))
+		delete(workingFilters, "warehouse_id")
+	}
+
 	// 处理关键字搜索
 	if keyword, ok := workingFilters["keyword"].(string); ok && keyword != "" {
 		query = query.Where(
@@ -99,6 +220,32 @@ func GetInspectionRecordsWithFilters(page, pageSize int, filters map[string]inte
 		delete(workingFilters, "end_date")
 	}
 
+	// 处理提交时间和修改时间范围过滤
+	auditRanges := []struct{ key, condition string }{
+		{"created_start", "created_at >= ?"},
+		{"created_end", "created_at <= ?"},
+		{"updated_start", "updated_at >= ?"},
+		{"updated_end", "updated_at <= ?"},
+	}
+	for _, r := range auditRanges {
+		if value, ok := workingFilters[r.key].(time.Time); ok {
+			query = query.Where(r.condition, value)
+		}
+		delete(workingFilters, r.key)
+	}
+
+	// 处理排序，只允许按白名单中的字段排序，默认保持原有顺序
+	orderBy := ""
+	if sortBy, ok := workingFilters["sort_by"].(string); ok && sortableRecordFields[sortBy] {
+		direction := "DESC"
+		if sortOrder, ok := workingFilters["sort_order"].(string); ok && strings.EqualFold(sortOrder, "asc") {
+			direction = "ASC"
+		}
+		orderBy = sortBy + " " + direction + ", id " + direction
+	}
+	delete(workingFilters, "sort_by")
+	delete(workingFilters, "sort_order")
+
 	// 处理JSON字段的过滤（支持多值查询，用逗号分隔）
 	jsonFields := []string{"pin_status", "main_wall_status", "warehouse_foundation"}
 	for _, fieldName := range jsonFields {
@@ -133,6 +280,9 @@ func GetInspectionRecordsWithFilters(page, pageSize int, filters map[string]inte
 	offset := (page - 1) * pageSize
 
 	// 查询分页数据
+	if orderBy != "" {
+		query = query.Order(orderBy)
+	}
 	if err := query.Offset(offset).Limit(pageSize).Find(&records).Error; err != nil {
 		return 0, nil, err
 	}
@@ -140,18 +290,35 @@ func GetInspectionRecordsWithFilters(page, pageSize int, filters map[string]inte
 	return total, records, nil
 }
 
-// UpdateInspectionRecord 更新点检记录
-func UpdateInspectionRecord(record *models.InspectionRecord) (*models.InspectionRecord, error) {
-	if err := database.DB.Save(record).Error; err != nil {
+// UpdateInspectionRecord 更新点检记录，记录归属和提交时间保持不变，修改前后的差异写入版本历史
+// 已驳回或已审核通过的记录修改后需要重新审核
+func UpdateInspectionRecord(record *models.InspectionRecord, userID int) (*models.InspectionRecord, error) {
+	if err := ValidateRecordChecklist(record); err != nil {
 		return nil, err
 	}
-	return record, nil
+	markResubmitted(record)
+	return saveRecordWithRevision(record, models.RevisionActionUpdate, userID)
 }
 
-// DeleteInspectionRecord 删除点检记录
-func DeleteInspectionRecord(id int) error {
-	if err := database.DB.Delete(&models.InspectionRecord{}, id).Error; err != nil {
-		return err
-	}
-	return nil
+// DeleteInspectionRecord 删除点检记录，记录移入回收站，可由管理员恢复
+func DeleteInspectionRecord(id int, userID int) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var record models.InspectionRecord
+		if err := tx.First(&record, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRecordNotFound
+			}
+			return err
+		}
+		if err := tx.Model(&record).Updates(map[string]interface{}{
+			"deleted_by": userID,
+			"updated_by": userID,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&record).Error; err != nil {
+			return err
+		}
+		return recordRevision(tx, &record, models.RevisionActionDelete, userID)
+	})
 }
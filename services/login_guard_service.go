@@ -0,0 +1,226 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrLoginLockNotFound 锁定记录不存在
+var ErrLoginLockNotFound = errors.New("login lock not found")
+
+// LoginLockedError 登录因连续失败被临时锁定
+type LoginLockedError struct {
+	Until time.Time
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, try again after %s", e.Until.Format("2006-01-02 15:04:05"))
+}
+
+// RetryAfter 返回距离解锁的秒数
+func (e *LoginLockedError) RetryAfter() int {
+	seconds := int(time.Until(e.Until).Seconds()) + 1
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// LoginGuardConfig 登录防暴力破解配置
+type LoginGuardConfig struct {
+	MaxFailures   int           // 同一用户名连续失败多少次后锁定
+	IPMaxFailures int           // 同一IP连续失败多少次后锁定
+	FailureWindow time.Duration // 超过该时间未再失败则重新计数
+	LockBase      time.Duration // 首次锁定时长，之后每次翻倍
+	LockMax       time.Duration // 最长锁定时长
+}
+
+// loginGuard 登录防暴力破解配置，可以通过 SetLoginGuardConfig 覆盖
+var loginGuard = LoginGuardConfig{
+	MaxFailures:   5,
+	IPMaxFailures: 20,
+	FailureWindow: 15 * time.Minute,
+	LockBase:      time.Minute,
+	LockMax:       24 * time.Hour,
+}
+
+// SetLoginGuardConfig 设置登录防暴力破解配置
+func SetLoginGuardConfig(cfg LoginGuardConfig) {
+	loginGuard = cfg
+}
+
+// userLockKey 按用户名统计的锁定键
+func userLockKey(username string) string {
+	return "user:" + username
+}
+
+// ipLockKey 按IP统计的锁定键
+func ipLockKey(ip string) string {
+	return "ip:" + ip
+}
+
+// CheckLoginAllowed 检查用户名和IP当前是否处于锁定状态
+func CheckLoginAllowed(username, ip string) error {
+	keys := []string{ipLockKey(ip)}
+	if username != "" {
+		keys = append(keys, userLockKey(username))
+	}
+
+	var locks []models.LoginLock
+	if err := database.DB.Where("`key` IN ? AND locked_until > ?", keys, time.Now()).Find(&locks).Error; err != nil {
+		return err
+	}
+
+	var until time.Time
+	for _, lock := range locks {
+		if lock.LockedUntil.After(until) {
+			until = *lock.LockedUntil
+		}
+	}
+	if !until.IsZero() {
+		return &LoginLockedError{Until: until}
+	}
+	return nil
+}
+
+// RecordLoginFailure 记录一次登录失败，达到阈值后按指数退避锁定
+func RecordLoginFailure(username, ip string) {
+	if username != "" {
+		if err := registerFailure(userLockKey(username), loginGuard.MaxFailures); err != nil {
+			log.Printf("failed to record login failure for %s: %v", username, err)
+		}
+	}
+	if err := registerFailure(ipLockKey(ip), loginGuard.IPMaxFailures); err != nil {
+		log.Printf("failed to record login failure for %s: %v", ip, err)
+	}
+}
+
+// registerFailure 累加指定键的失败次数
+func registerFailure(key string, threshold int) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		lock := models.LoginLock{Key: key}
+		if err := tx.Where(models.LoginLock{Key: key}).FirstOrCreate(&lock).Error; err != nil {
+			return err
+		}
+
+		// 距上次失败已超过统计窗口，重新计数
+		if now.Sub(lock.LastFailureAt) > loginGuard.FailureWindow {
+			lock.Failures = 0
+		}
+		lock.Failures++
+		lock.LastFailureAt = now
+
+		if threshold > 0 && lock.Failures >= threshold {
+			lock.LockCount++
+			lock.Failures = 0
+			until := now.Add(lockDuration(lock.LockCount))
+			lock.LockedUntil = &until
+		}
+		return tx.Save(&lock).Error
+	})
+}
+
+// lockDuration 计算第 n 次锁定的时长：LockBase * 2^(n-1)，不超过 LockMax
+func lockDuration(n int) time.Duration {
+	duration := loginGuard.LockBase
+	for i := 1; i < n; i++ {
+		duration *= 2
+		if duration >= loginGuard.LockMax {
+			return loginGuard.LockMax
+		}
+	}
+	if duration > loginGuard.LockMax {
+		return loginGuard.LockMax
+	}
+	return duration
+}
+
+// RecordLoginSuccess 登录成功后清除该用户名的失败计数，并重置IP的连续失败次数
+func RecordLoginSuccess(username, ip string) {
+	if username != "" {
+		if err := database.DB.Where("`key` = ?", userLockKey(username)).Delete(&models.LoginLock{}).Error; err != nil {
+			log.Printf("failed to reset login failures for %s: %v", username, err)
+		}
+	}
+	if err := database.DB.Model(&models.LoginLock{}).Where("`key` = ?", ipLockKey(ip)).
+		Update("failures", 0).Error; err != nil {
+		log.Printf("failed to reset login failures for %s: %v", ip, err)
+	}
+}
+
+// WriteLoginAudit 写入登录审计日志，写入失败只记录日志不影响登录流程
+func WriteLoginAudit(attempt *models.LoginAttempt) {
+	if err := database.DB.Create(attempt).Error; err != nil {
+		log.Printf("failed to write login audit: %v", err)
+	}
+}
+
+// GetLoginAttemptsWithFilters 分页查询登录审计日志
+func GetLoginAttemptsWithFilters(page, pageSize int, filters map[string]interface{}) (int64, []models.LoginAttempt, error) {
+	var attempts []models.LoginAttempt
+	var total int64
+	query := database.DB.Model(&models.LoginAttempt{})
+
+	if username, ok := filters["username"].(string); ok && username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if ip, ok := filters["ip"].(string); ok && ip != "" {
+		query = query.Where("ip = ?", ip)
+	}
+	if success, ok := filters["success"].(string); ok && success != "" {
+		if value, err := strconv.ParseBool(success); err == nil {
+			query = query.Where("success = ?", value)
+		}
+	}
+	if startDate, ok := filters["start_date"].(time.Time); ok {
+		query = query.Where("created_at >= ?", startDate)
+	}
+	if endDate, ok := filters["end_date"].(time.Time); ok {
+		query = query.Where("created_at <= ?", endDate)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return 0, nil, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&attempts).Error; err != nil {
+		return 0, nil, err
+	}
+	return total, attempts, nil
+}
+
+// GetActiveLoginLocks 获取当前处于锁定状态的记录
+func GetActiveLoginLocks() ([]models.LoginLock, error) {
+	var locks []models.LoginLock
+	if err := database.DB.Where("locked_until > ?", time.Now()).Order("locked_until DESC").Find(&locks).Error; err != nil {
+		return nil, err
+	}
+	return locks, nil
+}
+
+// UnlockLogin 管理员解除锁定，同时清空失败计数和退避等级
+func UnlockLogin(id int) error {
+	result := database.DB.Delete(&models.LoginLock{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrLoginLockNotFound
+	}
+	return nil
+}
+
+// UnlockUserLogin 管理员按用户名解除锁定
+func UnlockUserLogin(username string) error {
+	return database.DB.Where("`key` = ?", userLockKey(username)).Delete(&models.LoginLock{}).Error
+}
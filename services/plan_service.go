@@ -0,0 +1,372 @@
+package services
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrPlanNotFound 点检计划不存在
+var ErrPlanNotFound = errors.New("inspection plan not found")
+
+// ErrPlanReferenced 仓房或挡粮门仍被点检计划引用，不能删除
+var ErrPlanReferenced = errors.New("warehouse or grain door is still used by inspection plans")
+
+// planQuery 点检计划查询，预加载挡粮门、仓房和执行人
+func planQuery() *gorm.DB {
+	return database.DB.Preload("GrainDoor.Warehouse.Unit").Preload("Warehouse.Unit").Preload("Assignee")
+}
+
+// GetInspectionPlans 获取点检计划，warehouseID 大于 0 时只返回该仓房及其挡粮门的计划
+func GetInspectionPlans(warehouseID int) ([]models.InspectionPlan, error) {
+	var plans []models.InspectionPlan
+	query := planQuery().Order("id")
+	if warehouseID > 0 {
+		query = query.Where("warehouse_id = ? OR grain_door_id IN (?)", warehouseID,
+			database.DB.Model(&models.GrainDoor{}).Select("id").Where("warehouse_id = ?", warehouseID))
+	}
+	if err := query.Find(&plans).Error; err != nil {
+		return nil, err
+	}
+	return plans, nil
+}
+
+// GetInspectionPlanByID 根据ID获取点检计划
+func GetInspectionPlanByID(id int) (*models.InspectionPlan, error) {
+	var plan models.InspectionPlan
+	if err := planQuery().First(&plan, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPlanNotFound
+		}
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// validSeasonDay 校验入粮季节日期，格式 MM-DD
+func validSeasonDay(day string) bool {
+	if len(day) != 5 {
+		return false
+	}
+	_, err := time.Parse("01-02", day)
+	return err == nil
+}
+
+// checkInspectionPlan 校验点检计划，返回 *FieldError 或主数据不存在的错误
+func checkInspectionPlan(plan *models.InspectionPlan) error {
+	if (plan.GrainDoorID == nil) == (plan.WarehouseID == nil) {
+		return &FieldError{Field: "grain_door_id", Message: "exactly one of grain_door_id and warehouse_id is required"}
+	}
+	if !models.ValidFrequency(plan.Frequency) {
+		return &FieldError{Field: "frequency", Message: "must be one of daily, weekly, monthly"}
+	}
+	if plan.SeasonFrequency != "" {
+		if !models.ValidFrequency(plan.SeasonFrequency) {
+			return &FieldError{Field: "season_frequency", Message: "must be one of daily, weekly, monthly"}
+		}
+		if !validSeasonDay(plan.SeasonStart) {
+			return &FieldError{Field: "season_start", Message: "expected format MM-DD"}
+		}
+		if !validSeasonDay(plan.SeasonEnd) {
+			return &FieldError{Field: "season_end", Message: "expected format MM-DD"}
+		}
+	}
+	if plan.EndDate != nil && plan.EndDate.Before(plan.StartDate) {
+		return &FieldError{Field: "end_date", Message: "must not be earlier than start_date"}
+	}
+
+	if plan.GrainDoorID != nil {
+		if _, err := GetGrainDoorByID(*plan.GrainDoorID); err != nil {
+			return err
+		}
+	}
+	if plan.WarehouseID != nil {
+		if _, err := GetWarehouseByID(*plan.WarehouseID); err != nil {
+			return err
+		}
+	}
+	if plan.AssigneeID != nil {
+		if _, err := GetUserByID(*plan.AssigneeID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateInspectionPlan 新建点检计划
+func CreateInspectionPlan(plan *models.InspectionPlan) (*models.InspectionPlan, error) {
+	if err := checkInspectionPlan(plan); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Create(plan).Error; err != nil {
+		return nil, err
+	}
+	return GetInspectionPlanByID(plan.ID)
+}
+
+// UpdateInspectionPlan 更新点检计划
+func UpdateInspectionPlan(plan *models.InspectionPlan) (*models.InspectionPlan, error) {
+	if err := checkInspectionPlan(plan); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Omit("GrainDoor", "Warehouse", "Assignee", "created_at", "created_by").
+		Save(plan).Error; err != nil {
+		return nil, err
+	}
+	return GetInspectionPlanByID(plan.ID)
+}
+
+// DeleteInspectionPlan 删除点检计划
+func DeleteInspectionPlan(id int) error {
+	if _, err := GetInspectionPlanByID(id); err != nil {
+		return err
+	}
+	return database.DB.Delete(&models.InspectionPlan{}, id).Error
+}
+
+// countPlansReferencing 统计引用仓房或挡粮门的点检计划数量
+func countPlansReferencing(column string, id int) (int64, error) {
+	var count int64
+	err := database.DB.Model(&models.InspectionPlan{}).Where(column+" = ?", id).Count(&count).Error
+	return count, err
+}
+
+// planDoors 一次查询展开多个点检计划覆盖的挡粮门，按计划ID分组
+func planDoors(plans []models.InspectionPlan) (map[int][]models.GrainDoor, error) {
+	var doorIDs, warehouseIDs []int
+	for _, plan := range plans {
+		if plan.GrainDoorID != nil {
+			doorIDs = append(doorIDs, *plan.GrainDoorID)
+		} else if plan.WarehouseID != nil {
+			warehouseIDs = append(warehouseIDs, *plan.WarehouseID)
+		}
+	}
+	result := make(map[int][]models.GrainDoor, len(plans))
+	if len(doorIDs) == 0 && len(warehouseIDs) == 0 {
+		return result, nil
+	}
+
+	var doors []models.GrainDoor
+	query := database.DB.Preload("Warehouse.Unit").Order("position")
+	switch {
+	case len(warehouseIDs) == 0:
+		query = query.Where("id IN ?", doorIDs)
+	case len(doorIDs) == 0:
+		query = query.Where("warehouse_id IN ?", warehouseIDs)
+	default:
+		query = query.Where("id IN ? OR warehouse_id IN ?", doorIDs, warehouseIDs)
+	}
+	if err := query.Find(&doors).Error; err != nil {
+		return nil, err
+	}
+
+	for _, plan := range plans {
+		for _, door := range doors {
+			if (plan.GrainDoorID != nil && door.ID == *plan.GrainDoorID) ||
+				(plan.GrainDoorID == nil && plan.WarehouseID != nil && door.WarehouseID == *plan.WarehouseID) {
+				result[plan.ID] = append(result[plan.ID], door)
+			}
+		}
+	}
+	return result, nil
+}
+
+// lastInspectionTimes 获取各挡粮门最近一次点检时间，不含回收站中的记录
+func lastInspectionTimes(doorIDs []int) (map[int]time.Time, error) {
+	last := make(map[int]time.Time)
+	if len(doorIDs) == 0 {
+		return last, nil
+	}
+	var rows []struct {
+		GrainDoorID    int
+		InspectionTime aggregateTime
+	}
+	if err := database.DB.Model(&models.InspectionRecord{}).
+		Select("grain_door_id, MAX(inspection_time) AS inspection_time").
+		Where("grain_door_id IN ?", doorIDs).
+		Group("grain_door_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		last[row.GrainDoorID] = row.InspectionTime.Time
+	}
+	return last, nil
+}
+
+// aggregateTime 读取聚合查询返回的时间，SQLite 的聚合结果不带列类型，时间以文本返回
+type aggregateTime struct {
+	time.Time
+}
+
+// aggregateTimeLayouts SQLite 驱动保存时间使用的文本格式
+var aggregateTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+}
+
+// Scan 实现 sql.Scanner
+func (t *aggregateTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		t.Time = time.Time{}
+		return nil
+	case time.Time:
+		t.Time = v
+		return nil
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	}
+	return fmt.Errorf("cannot scan %T into time", value)
+}
+
+// Value 实现 driver.Valuer
+func (t aggregateTime) Value() (driver.Value, error) {
+	return t.Time, nil
+}
+
+// parse 按 SQLite 驱动保存时间的格式解析文本
+func (t *aggregateTime) parse(value string) error {
+	for _, layout := range aggregateTimeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse time %q", value)
+}
+
+// planTask 按最近一次点检时间计算挡粮门在当前时间的点检任务状态
+// 最近一次点检所在周期的下一个周期结束前必须再次点检；从未点检时，计划开始所在周期结束前必须点检
+func planTask(plan *models.InspectionPlan, door *models.GrainDoor, last time.Time, now time.Time) models.PlanTask {
+	task := models.PlanTask{
+		PlanID:     plan.ID,
+		PlanName:   plan.Name,
+		GrainDoor:  door,
+		AssigneeID: plan.AssigneeID,
+		Frequency:  plan.FrequencyAt(now),
+	}
+	if task.AssigneeID == nil && door.Warehouse != nil {
+		task.AssigneeID = door.Warehouse.CaretakerID
+	}
+	task.PeriodStart, task.PeriodEnd = plan.PlanPeriod(now)
+
+	// 周期按当前时间所在时区的自然日划分
+	last = last.In(now.Location())
+	if last.IsZero() || last.Before(plan.StartDate) {
+		_, task.DueAt = plan.PlanPeriod(plan.StartDate.In(now.Location()))
+	} else {
+		lastInspection := last
+		task.LastInspection = &lastInspection
+		_, lastPeriodEnd := plan.PlanPeriod(last)
+		_, task.DueAt = plan.PlanPeriod(lastPeriodEnd)
+	}
+
+	switch {
+	case task.LastInspection != nil && !task.LastInspection.Before(task.PeriodStart):
+		task.Status = models.TaskStatusDone
+	case now.Before(task.DueAt):
+		task.Status = models.TaskStatusPending
+	default:
+		task.Status = models.TaskStatusOverdue
+		task.OverdueDays = int(now.Sub(task.DueAt).Hours()/24) + 1
+	}
+	return task
+}
+
+// ComputePlanTasks 计算启用且在有效期内的点检计划在当前时间的点检任务
+func ComputePlanTasks(now time.Time) ([]models.PlanTask, error) {
+	var plans []models.InspectionPlan
+	if err := database.DB.Where("enabled = ? AND start_date <= ?", true, now).
+		Where("end_date IS NULL OR end_date >= ?", now).
+		Order("id").Find(&plans).Error; err != nil {
+		return nil, err
+	}
+
+	type planDoor struct {
+		plan *models.InspectionPlan
+		door models.GrainDoor
+	}
+	doorsByPlan, err := planDoors(plans)
+	if err != nil {
+		return nil, err
+	}
+	var pairs []planDoor
+	var doorIDs []int
+	for i := range plans {
+		for _, door := range doorsByPlan[plans[i].ID] {
+			pairs = append(pairs, planDoor{plan: &plans[i], door: door})
+			doorIDs = append(doorIDs, door.ID)
+		}
+	}
+
+	last, err := lastInspectionTimes(doorIDs)
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]models.PlanTask, 0, len(pairs))
+	for i := range pairs {
+		tasks = append(tasks, planTask(pairs[i].plan, &pairs[i].door, last[pairs[i].door.ID], now))
+	}
+	return tasks, nil
+}
+
+// GetUserTasks 获取用户当前的点检任务，包括本周期待点检、已逾期和已完成的任务，逾期的排在前面
+func GetUserTasks(userID int, now time.Time) ([]models.PlanTask, error) {
+	tasks, err := ComputePlanTasks(now)
+	if err != nil {
+		return nil, err
+	}
+	mine := make([]models.PlanTask, 0)
+	for _, task := range tasks {
+		if task.AssigneeID != nil && *task.AssigneeID == userID {
+			mine = append(mine, task)
+		}
+	}
+	statusOrder := map[string]int{models.TaskStatusOverdue: 0, models.TaskStatusPending: 1, models.TaskStatusDone: 2}
+	sort.SliceStable(mine, func(i, j int) bool {
+		if statusOrder[mine[i].Status] != statusOrder[mine[j].Status] {
+			return statusOrder[mine[i].Status] < statusOrder[mine[j].Status]
+		}
+		return mine[i].DueAt.Before(mine[j].DueAt)
+	})
+	return mine, nil
+}
+
+// GetOverdueTasks 获取已逾期的点检任务，scope 不为 nil 时只返回其中单位的任务，unitID 大于 0 时只返回该单位的任务
+// 按逾期天数从多到少排序
+func GetOverdueTasks(scope []int, unitID int, now time.Time) ([]models.PlanTask, error) {
+	tasks, err := ComputePlanTasks(now)
+	if err != nil {
+		return nil, err
+	}
+	overdue := make([]models.PlanTask, 0)
+	for _, task := range tasks {
+		if task.Status != models.TaskStatusOverdue || task.GrainDoor.Warehouse == nil {
+			continue
+		}
+		taskUnitID := task.GrainDoor.Warehouse.UnitID
+		if scope != nil && !containsID(scope, taskUnitID) {
+			continue
+		}
+		if unitID > 0 && taskUnitID != unitID {
+			continue
+		}
+		overdue = append(overdue, task)
+	}
+	sort.SliceStable(overdue, func(i, j int) bool {
+		return overdue[i].OverdueDays > overdue[j].OverdueDays
+	})
+	return overdue, nil
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/skip2/go-qrcode"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidQRToken 二维码内容无法识别、签名不正确或已被重新生成
+var ErrInvalidQRToken = errors.New("invalid or outdated grain door qr code")
+
+// ErrQRTokenMismatch 扫描的二维码与提交的挡粮门不一致
+var ErrQRTokenMismatch = errors.New("qr code does not match the selected grain door")
+
+// qrTokenPrefix 挡粮门二维码内容前缀，格式为 dlm:door:<挡粮门ID>:<二维码版本>:<签名>
+const qrTokenPrefix = "dlm:door:"
+
+// qrConfig 二维码签名和标签打印配置，通过 SetQRConfig 设置
+var qrConfig struct {
+	Secret    []byte
+	LabelFont string
+}
+
+// SetQRConfig 设置二维码签名密钥和标签字体文件
+func SetQRConfig(secret, labelFont string) {
+	qrConfig.Secret = []byte(secret)
+	qrConfig.LabelFont = labelFont
+}
+
+// signDoorQR 计算挡粮门二维码签名，截取前 16 字节以缩短二维码内容
+func signDoorQR(doorID, version int) string {
+	mac := hmac.New(sha256.New, qrConfig.Secret)
+	fmt.Fprintf(mac, "door:%d:%d", doorID, version)
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// DoorQRToken 生成挡粮门二维码内容
+func DoorQRToken(door *models.GrainDoor) string {
+	return fmt.Sprintf("%s%d:%d:%s", qrTokenPrefix, door.ID, door.QRVersion, signDoorQR(door.ID, door.QRVersion))
+}
+
+// parseDoorQRToken 解析二维码内容并校验签名
+func parseDoorQRToken(token string) (doorID, version int, err error) {
+	if !strings.HasPrefix(token, qrTokenPrefix) {
+		return 0, 0, ErrInvalidQRToken
+	}
+	parts := strings.Split(strings.TrimPrefix(token, qrTokenPrefix), ":")
+	if len(parts) != 3 {
+		return 0, 0, ErrInvalidQRToken
+	}
+	if doorID, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, 0, ErrInvalidQRToken
+	}
+	if version, err = strconv.Atoi(parts[1]); err != nil {
+		return 0, 0, ErrInvalidQRToken
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(signDoorQR(doorID, version))) {
+		return 0, 0, ErrInvalidQRToken
+	}
+	return doorID, version, nil
+}
+
+// VerifyDoorQRToken 校验扫描的二维码并返回对应的挡粮门，挡粮门必须在用户可访问的单位范围内
+// 二维码内容是静态的，只能证明提交人拿到过当前版本的标签，拍下标签的照片同样可以通过校验，不能证明到场；
+// 标签外泄时通过 RotateDoorQR 重新生成使旧二维码失效
+func VerifyDoorQRToken(token string, scope []int) (*models.GrainDoor, error) {
+	doorID, version, err := parseDoorQRToken(strings.TrimSpace(token))
+	if err != nil {
+		return nil, err
+	}
+	door, err := ResolveRecordDoor(doorID, scope)
+	if err != nil {
+		if errors.Is(err, ErrGrainDoorNotFound) {
+			return nil, ErrInvalidQRToken
+		}
+		return nil, err
+	}
+	// 二维码重新生成后，旧的标签不再有效
+	if door.QRVersion != version {
+		return nil, ErrInvalidQRToken
+	}
+	return door, nil
+}
+
+// RotateDoorQR 重新生成挡粮门二维码，已打印的旧二维码随之失效
+// 版本号在数据库中递增并在同一事务中读回，并发重新生成时不会得到相同的版本
+func RotateDoorQR(id int) (*models.GrainDoor, error) {
+	var door models.GrainDoor
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.GrainDoor{}).Where("id = ?", id).
+			UpdateColumn("qr_version", gorm.Expr("qr_version + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrGrainDoorNotFound
+		}
+		return tx.Preload("Warehouse.Unit").First(&door, id).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &door, nil
+}
+
+// DoorQRPNG 生成挡粮门二维码 PNG 图片
+func DoorQRPNG(door *models.GrainDoor, size int) ([]byte, error) {
+	return qrcode.Encode(DoorQRToken(door), qrcode.Medium, size)
+}
+
+// GetGrainDoorsForLabels 获取需要打印二维码标签的挡粮门，ids 为空时按仓房或单位筛选
+func GetGrainDoorsForLabels(ids []int, warehouseID, unitID int) ([]models.GrainDoor, error) {
+	var doors []models.GrainDoor
+	query := database.DB.Preload("Warehouse.Unit").
+		Joins("JOIN warehouses ON warehouses.id = grain_doors.warehouse_id").
+		Order("warehouses.unit_id, warehouses.number, grain_doors.position")
+	if len(ids) > 0 {
+		query = query.Where("grain_doors.id IN ?", ids)
+	}
+	if warehouseID > 0 {
+		query = query.Where("grain_doors.warehouse_id = ?", warehouseID)
+	}
+	if unitID > 0 {
+		query = query.Where("warehouses.unit_id = ?", unitID)
+	}
+	if err := query.Find(&doors).Error; err != nil {
+		return nil, err
+	}
+	return doors, nil
+}
+
+// 标签纸排版：A4 纵向，每页 3 列 4 行，单位毫米
+const (
+	labelColumns = 3
+	labelRows    = 4
+	labelMargin  = 10.0
+	labelQRSize  = 42.0
+)
+
+// doorLabelLines 标签上显示的文字；未配置中文字体时只能显示 ASCII 字符，改为显示编号
+func doorLabelLines(door *models.GrainDoor, unicode bool) []string {
+	unitName, warehouseName := "", ""
+	if door.Warehouse != nil {
+		warehouseName = door.Warehouse.Name
+		if door.Warehouse.Unit != nil {
+			unitName = door.Warehouse.Unit.Name
+		}
+	}
+	if unicode {
+		return []string{unitName, warehouseName + "  挡粮门 " + door.Position, fmt.Sprintf("编号 %d", door.ID)}
+	}
+	return []string{fmt.Sprintf("DOOR #%d", door.ID), fmt.Sprintf("WAREHOUSE #%d", door.WarehouseID), asciiOnly(door.Position)}
+}
+
+// asciiOnly 去除非 ASCII 字符
+func asciiOnly(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r < 128 {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// DoorQRLabelsPDF 生成可打印的挡粮门二维码标签 PDF
+func DoorQRLabelsPDF(doors []models.GrainDoor) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetAutoPageBreak(false, 0)
+
+	fontFamily, unicode := "Helvetica", false
+	if qrConfig.LabelFont != "" {
+		pdf.AddUTF8Font("label", "", qrConfig.LabelFont)
+		fontFamily, unicode = "label", true
+	}
+
+	pageWidth, pageHeight := pdf.GetPageSize()
+	cellWidth := (pageWidth - 2*labelMargin) / labelColumns
+	cellHeight := (pageHeight - 2*labelMargin) / labelRows
+
+	for i := range doors {
+		door := &doors[i]
+		slot := i % (labelColumns * labelRows)
+		if slot == 0 {
+			pdf.AddPage()
+		}
+		x := labelMargin + float64(slot%labelColumns)*cellWidth
+		y := labelMargin + float64(slot/labelColumns)*cellHeight
+
+		// 裁切框
+		pdf.SetDrawColor(200, 200, 200)
+		pdf.Rect(x, y, cellWidth, cellHeight, "D")
+
+		png, err := DoorQRPNG(door, 512)
+		if err != nil {
+			return nil, err
+		}
+		imageName := fmt.Sprintf("door-%d", door.ID)
+		options := gofpdf.ImageOptions{ImageType: "PNG"}
+		pdf.RegisterImageOptionsReader(imageName, options, bytes.NewReader(png))
+		pdf.ImageOptions(imageName, x+(cellWidth-labelQRSize)/2, y+4, labelQRSize, labelQRSize, false, options, 0, "")
+
+		pdf.SetFont(fontFamily, "", 10)
+		textY := y + 4 + labelQRSize + 2
+		for _, line := range doorLabelLines(door, unicode) {
+			pdf.SetXY(x, textY)
+			pdf.CellFormat(cellWidth, 5, line, "", 0, "C", false, 0, "")
+			textY += 5
+		}
+	}
+
+	if len(doors) == 0 {
+		pdf.AddPage()
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"DLM_backend/models"
+)
+
+func TestVerifyDoorQRToken(t *testing.T) {
+	db := setupTestDB(t)
+	SetQRConfig("test-secret", "")
+
+	mustCreate(t, db, &models.Unit{ID: 1, Name: "一库"})
+	mustCreate(t, db, &models.Unit{ID: 2, Name: "二库"})
+	mustCreate(t, db, &models.Warehouse{ID: 1, UnitID: 1, Number: "1", Name: "1号仓"})
+	mustCreate(t, db, &models.GrainDoor{ID: 1, WarehouseID: 1, Position: "东"})
+	mustCreate(t, db, &models.GrainDoor{ID: 2, WarehouseID: 1, Position: "西"})
+
+	valid := DoorQRToken(&models.GrainDoor{ID: 1, QRVersion: 1})
+	rotated := DoorQRToken(&models.GrainDoor{ID: 2, QRVersion: 1})
+	if _, err := RotateDoorQR(2); err != nil {
+		t.Fatalf("rotate qr: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		scope   []int
+		wantID  int
+		wantErr error
+	}{
+		{name: "valid", token: valid, wantID: 1},
+		{name: "surrounding whitespace", token: "  " + valid + "\n", wantID: 1},
+		{name: "valid in scope", token: valid, scope: []int{1}, wantID: 1},
+		{name: "out of scope", token: valid, scope: []int{2}, wantErr: ErrUnitNotAccessible},
+		{name: "empty", token: "", wantErr: ErrInvalidQRToken},
+		{name: "wrong prefix", token: "dlm:unit:1:1:" + signDoorQR(1, 1), wantErr: ErrInvalidQRToken},
+		{name: "missing signature", token: "dlm:door:1:1", wantErr: ErrInvalidQRToken},
+		{name: "non numeric id", token: "dlm:door:x:1:" + signDoorQR(1, 1), wantErr: ErrInvalidQRToken},
+		{name: "tampered door id", token: "dlm:door:2:1:" + signDoorQR(1, 1), wantErr: ErrInvalidQRToken},
+		{name: "tampered version", token: "dlm:door:1:2:" + signDoorQR(1, 1), wantErr: ErrInvalidQRToken},
+		{name: "outdated after rotation", token: rotated, wantErr: ErrInvalidQRToken},
+		{name: "unknown door", token: DoorQRToken(&models.GrainDoor{ID: 99, QRVersion: 1}), wantErr: ErrInvalidQRToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			door, err := VerifyDoorQRToken(tt.token, tt.scope)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				if door != nil {
+					t.Fatalf("door = %+v, want nil", door)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if door.ID != tt.wantID {
+				t.Fatalf("door id = %d, want %d", door.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestVerifyDoorQRTokenSecret(t *testing.T) {
+	setupTestDB(t)
+	SetQRConfig("old-secret", "")
+	token := DoorQRToken(&models.GrainDoor{ID: 1, QRVersion: 1})
+	SetQRConfig("new-secret", "")
+	if _, _, err := parseDoorQRToken(token); !errors.Is(err, ErrInvalidQRToken) {
+		t.Fatalf("token signed with another secret: error = %v, want %v", err, ErrInvalidQRToken)
+	}
+}
+
+func TestRotateDoorQR(t *testing.T) {
+	db := setupTestDB(t)
+	mustCreate(t, db, &models.Unit{ID: 1, Name: "一库"})
+	mustCreate(t, db, &models.Warehouse{ID: 1, UnitID: 1, Number: "1", Name: "1号仓"})
+	mustCreate(t, db, &models.GrainDoor{ID: 1, WarehouseID: 1, Position: "东"})
+
+	for want := 2; want <= 3; want++ {
+		door, err := RotateDoorQR(1)
+		if err != nil {
+			t.Fatalf("rotate qr: %v", err)
+		}
+		if door.QRVersion != want {
+			t.Fatalf("qr version = %d, want %d", door.QRVersion, want)
+		}
+		if door.Warehouse == nil || door.Warehouse.Unit == nil {
+			t.Fatalf("rotated door is missing its warehouse and unit")
+		}
+	}
+	if _, err := RotateDoorQR(99); !errors.Is(err, ErrGrainDoorNotFound) {
+		t.Fatalf("unknown door: error = %v, want %v", err, ErrGrainDoorNotFound)
+	}
+}
@@ -0,0 +1,415 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ErrTicketNotFound 整改工单不存在
+var ErrTicketNotFound = errors.New("rectification ticket not found")
+
+// ErrInvalidTicketTransition 整改工单当前状态不允许该操作
+var ErrInvalidTicketTransition = errors.New("invalid rectification ticket status transition")
+
+// ErrTicketNotAssigned 只有整改责任人或整改管理员可以处理工单
+var ErrTicketNotAssigned = errors.New("rectification ticket is not assigned to you")
+
+// rectificationDeadline 整改工单的默认整改期限
+var rectificationDeadline = 72 * time.Hour
+
+// SetRectificationDeadline 设置整改工单的默认整改期限
+func SetRectificationDeadline(deadline time.Duration) {
+	rectificationDeadline = deadline
+}
+
+// abnormalItem 点检记录中的一个异常项
+type abnormalItem struct {
+	Field       string
+	Code        string
+	Title       string
+	Description string
+	Images      []string
+}
+
+// abnormalItems 按点检记录使用的模板版本找出所有异常状态
+func abnormalItems(db *gorm.DB, record *models.InspectionRecord) ([]abnormalItem, error) {
+	template, err := recordTemplate(db, record)
+	if err != nil {
+		return nil, err
+	}
+	answers, err := record.ChecklistAnswers()
+	if err != nil {
+		return nil, err
+	}
+	if len(answers) == 0 {
+		answers = record.LegacyAnswers()
+	}
+
+	var items []abnormalItem
+	for _, field := range template.Items {
+		answer, ok := answers[field.Field]
+		if !ok {
+			continue
+		}
+		codes, _ := answer.Codes()
+		for _, code := range codes {
+			if !field.IsAbnormal(code) {
+				continue
+			}
+			items = append(items, abnormalItem{
+				Field:       field.Field,
+				Code:        code,
+				Title:       field.Label + "：" + field.LabelOf(code),
+				Description: answer.Description,
+				Images:      answer.Images,
+			})
+		}
+	}
+	return items, nil
+}
+
+// openRectificationTickets 为点检记录中尚未建单的异常项生成整改工单
+// 整改责任人默认为挡粮门所在仓房的保管责任人，没有时为记录提交人
+func openRectificationTickets(tx *gorm.DB, record *models.InspectionRecord) error {
+	items, err := abnormalItems(tx, record)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	var existing []models.RectificationTicket
+	if err := tx.Select("field", "item").Where("record_id = ?", record.ID).Find(&existing).Error; err != nil {
+		return err
+	}
+	opened := make(map[string]bool, len(existing))
+	for _, ticket := range existing {
+		opened[ticket.Field+"/"+ticket.Item] = true
+	}
+
+	var assigneeID *int
+	if record.UserID > 0 {
+		userID := record.UserID
+		assigneeID = &userID
+	}
+	if record.GrainDoorID != nil {
+		var door models.GrainDoor
+		if err := tx.Preload("Warehouse").First(&door, *record.GrainDoorID).Error; err == nil &&
+			door.Warehouse != nil && door.Warehouse.CaretakerID != nil {
+			assigneeID = door.Warehouse.CaretakerID
+		}
+	}
+
+	for _, item := range items {
+		if opened[item.Field+"/"+item.Code] {
+			continue
+		}
+		// 整改前图片优先使用该点检项的照片
+		beforeImages := record.Images
+		if len(item.Images) > 0 {
+			beforeImages, _ = json.Marshal(item.Images)
+		}
+		ticket := models.RectificationTicket{
+			RecordID:     record.ID,
+			UnitID:       record.UnitID,
+			GrainDoorID:  record.GrainDoorID,
+			Field:        item.Field,
+			Item:         item.Code,
+			Title:        item.Title,
+			Description:  item.Description,
+			AssigneeID:   assigneeID,
+			Deadline:     time.Now().Add(rectificationDeadline),
+			Status:       models.RectificationOpen,
+			BeforeImages: beforeImages,
+		}
+		ticket.SetCreator(record.UserID)
+		if err := tx.Create(&ticket).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRectificationTickets 获取带条件过滤的分页整改工单
+// filters 支持 unit_scope、unit_id、assignee_id、record_id、grain_door_id、status 和 overdue
+func GetRectificationTickets(page, pageSize int, filters map[string]interface{}) (int64, []models.RectificationTicket, error) {
+	var tickets []models.RectificationTicket
+	var total int64
+	query := database.DB.Model(&models.RectificationTicket{})
+
+	if scope, ok := filters["unit_scope"].([]int); ok {
+		query = query.Where("unit_id IN ?", scope)
+	}
+	for _, key := range []string{"unit_id", "assignee_id", "record_id", "grain_door_id"} {
+		if value, ok := filters[key].(int); ok {
+			query = query.Where(key+" = ?", value)
+		}
+	}
+	if status, ok := filters["status"].(string); ok && status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if overdue, ok := filters["overdue"].(bool); ok {
+		open := []string{models.RectificationOpen, models.RectificationInProgress}
+		if overdue {
+			query = query.Where("status IN ? AND deadline < ?", open, time.Now())
+		} else {
+			query = query.Where("status NOT IN ? OR deadline >= ?", open, time.Now())
+		}
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return 0, nil, err
+	}
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Assignee").Order("deadline ASC, id ASC").
+		Limit(pageSize).Offset(offset).Find(&tickets).Error; err != nil {
+		return 0, nil, err
+	}
+	return total, tickets, nil
+}
+
+// GetRectificationTicketByID 根据ID获取整改工单，包括已移入回收站的原点检记录
+func GetRectificationTicketByID(id int) (*models.RectificationTicket, error) {
+	var ticket models.RectificationTicket
+	if err := database.DB.Preload("Assignee").
+		Preload("Record", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		First(&ticket, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTicketNotFound
+		}
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// CheckTicketInScope 校验整改工单是否在用户可访问的单位范围内，不在范围内时按不存在处理
+func CheckTicketInScope(ticket *models.RectificationTicket, scope []int) error {
+	if scope == nil {
+		return nil
+	}
+	if ticket.UnitID == nil || !containsID(scope, *ticket.UnitID) {
+		return ErrTicketNotFound
+	}
+	return nil
+}
+
+// checkTicketHandler 校验用户能否处理整改工单
+func checkTicketHandler(ticket *models.RectificationTicket, userID int, canManage bool) error {
+	if canManage {
+		return nil
+	}
+	if ticket.AssigneeID == nil || *ticket.AssigneeID != userID {
+		return ErrTicketNotAssigned
+	}
+	return nil
+}
+
+// transitionTicket 校验工单状态后更新工单
+func transitionTicket(ticket *models.RectificationTicket, from, to string, userID int, updates map[string]interface{}) (*models.RectificationTicket, error) {
+	if ticket.Status != from {
+		return nil, fmt.Errorf("%w: cannot change from %s to %s", ErrInvalidTicketTransition, ticket.Status, to)
+	}
+	updates["status"] = to
+	if userID > 0 {
+		updates["updated_by"] = userID
+	}
+	// 以当前状态为条件更新，避免并发操作重复流转
+	result := database.DB.Model(&models.RectificationTicket{}).
+		Where("id = ? AND status = ?", ticket.ID, from).Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("%w: ticket status has changed", ErrInvalidTicketTransition)
+	}
+	return GetRectificationTicketByID(ticket.ID)
+}
+
+// StartRectification 开始整改，open -> in_progress
+func StartRectification(ticket *models.RectificationTicket, userID int, canManage bool) (*models.RectificationTicket, error) {
+	if err := checkTicketHandler(ticket, userID, canManage); err != nil {
+		return nil, err
+	}
+	return transitionTicket(ticket, models.RectificationOpen, models.RectificationInProgress, userID,
+		map[string]interface{}{"started_at": time.Now()})
+}
+
+// FixRectification 提交整改结果，in_progress -> fixed，必须上传整改后图片，图片须由处理人本人通过上传接口上传
+func FixRectification(ticket *models.RectificationTicket, afterImages []string, note string, userID int, canManage bool) (*models.RectificationTicket, error) {
+	if err := checkTicketHandler(ticket, userID, canManage); err != nil {
+		return nil, err
+	}
+	afterImages = compactImages(afterImages)
+	if len(afterImages) == 0 {
+		return nil, &FieldError{Field: "after_images", Message: "at least one photo after rectification is required"}
+	}
+	if err := checkUploadedImages(afterImages, userID); err != nil {
+		return nil, err
+	}
+	imagesJSON, _ := json.Marshal(afterImages)
+	return transitionTicket(ticket, models.RectificationInProgress, models.RectificationFixed, userID,
+		map[string]interface{}{
+			"after_images": datatypes.JSON(imagesJSON),
+			"fix_note":     note,
+			"fixed_at":     time.Now(),
+			"fixed_by":     userID,
+		})
+}
+
+// checkUploadedImages 校验图片都是 userID 通过上传接口上传并登记为附件的图片
+func checkUploadedImages(images []string, userID int) error {
+	unique := make(map[string]bool, len(images))
+	for _, image := range images {
+		unique[image] = true
+	}
+	var count int64
+	if err := database.DB.Model(&models.Attachment{}).Where("url IN ? AND uploader_id = ?", images, userID).
+		Distinct("url").Count(&count).Error; err != nil {
+		return err
+	}
+	if int(count) != len(unique) {
+		return &FieldError{Field: "after_images", Message: "must be images uploaded by you"}
+	}
+	return nil
+}
+
+// sameDoor 判断两条点检记录是否针对同一个挡粮门，没有挡粮门ID的历史记录按单位、仓号和门位置比较
+func sameDoor(a, b *models.InspectionRecord) bool {
+	if a.GrainDoorID != nil || b.GrainDoorID != nil {
+		return a.GrainDoorID != nil && b.GrainDoorID != nil && *a.GrainDoorID == *b.GrainDoorID
+	}
+	return a.Unit == b.Unit &&
+		models.NormalizeWarehouseNumber(a.WarehouseNumber) == models.NormalizeWarehouseNumber(b.WarehouseNumber) &&
+		models.NormalizeDoorPosition(a.GrainDoorPosition) == models.NormalizeDoorPosition(b.GrainDoorPosition)
+}
+
+// findFollowUpRecord 查找整改完成后同一挡粮门最近的一条点检记录
+func findFollowUpRecord(ticket *models.RectificationTicket) (*models.InspectionRecord, error) {
+	query := database.DB.Where("id <> ? AND inspection_time >= ?", ticket.RecordID, *ticket.FixedAt).
+		Order("inspection_time DESC")
+	if ticket.GrainDoorID != nil {
+		query = query.Where("grain_door_id = ?", *ticket.GrainDoorID)
+	} else if ticket.Record != nil {
+		query = query.Where("unit = ?", ticket.Record.Unit)
+	}
+	var candidates []models.InspectionRecord
+	if err := query.Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+	for i := range candidates {
+		if ticket.Record == nil || sameDoor(ticket.Record, &candidates[i]) {
+			return &candidates[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// VerifyRectification 复查整改结果，fixed -> verified
+// 必须有整改完成后同一挡粮门的点检记录，且该记录中此异常项已恢复正常；followUpID 为空时自动选用最近的一条
+// 复查记录中此异常项仍然存在时工单退回整改中，返回退回后的工单
+func VerifyRectification(ticket *models.RectificationTicket, followUpID *int, userID int) (*models.RectificationTicket, error) {
+	if ticket.Status != models.RectificationFixed {
+		return nil, fmt.Errorf("%w: cannot change from %s to %s",
+			ErrInvalidTicketTransition, ticket.Status, models.RectificationVerified)
+	}
+
+	var followUp *models.InspectionRecord
+	if followUpID != nil {
+		record, err := GetInspectionRecordByID(*followUpID)
+		if err != nil {
+			return nil, &FieldError{Field: "follow_up_record_id", Message: "inspection record not found"}
+		}
+		if record.ID == ticket.RecordID || ticket.Record == nil || !sameDoor(ticket.Record, record) {
+			return nil, &FieldError{Field: "follow_up_record_id", Message: "must be another inspection of the same grain door"}
+		}
+		if record.InspectionTime.Before(*ticket.FixedAt) {
+			return nil, &FieldError{Field: "follow_up_record_id", Message: "must be inspected after the rectification was fixed"}
+		}
+		followUp = record
+	} else {
+		record, err := findFollowUpRecord(ticket)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			return nil, &FieldError{Field: "follow_up_record_id", Message: "a follow-up inspection of the same grain door is required"}
+		}
+		followUp = record
+	}
+
+	items, err := abnormalItems(database.DB, followUp)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if item.Field == ticket.Field && item.Code == ticket.Item {
+			// 复查记录仍有此异常，退回重新整改
+			return rejectRectification(ticket, fmt.Sprintf("复查点检记录 %d 仍存在该异常", followUp.ID), userID,
+				map[string]interface{}{"follow_up_record_id": followUp.ID})
+		}
+	}
+
+	return transitionTicket(ticket, models.RectificationFixed, models.RectificationVerified, userID,
+		map[string]interface{}{
+			"verified_at":         time.Now(),
+			"verified_by":         userID,
+			"follow_up_record_id": followUp.ID,
+		})
+}
+
+// RejectRectification 复查未通过，退回重新整改，fixed -> in_progress
+func RejectRectification(ticket *models.RectificationTicket, reason string, userID int) (*models.RectificationTicket, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, &FieldError{Field: "reason", Message: "reason is required"}
+	}
+	return rejectRectification(ticket, reason, userID, map[string]interface{}{})
+}
+
+// rejectRectification 将已整改的工单退回整改中，清除整改结果，由责任人重新整改后再次提交
+// 退回后仍按原整改期限计算是否逾期
+func rejectRectification(ticket *models.RectificationTicket, reason string, userID int, updates map[string]interface{}) (*models.RectificationTicket, error) {
+	updates["return_reason"] = reason
+	updates["after_images"] = nil
+	updates["fixed_at"] = nil
+	updates["fixed_by"] = nil
+	return transitionTicket(ticket, models.RectificationFixed, models.RectificationInProgress, userID, updates)
+}
+
+// AssignRectification 调整整改工单的责任人和整改期限，已关闭的工单不能调整
+func AssignRectification(ticket *models.RectificationTicket, assigneeID *int, deadline *time.Time, userID int) (*models.RectificationTicket, error) {
+	if ticket.Status == models.RectificationVerified {
+		return nil, fmt.Errorf("%w: ticket is already verified", ErrInvalidTicketTransition)
+	}
+	updates := map[string]interface{}{}
+	if assigneeID != nil {
+		if _, err := GetUserByID(*assigneeID); err != nil {
+			return nil, err
+		}
+		updates["assignee_id"] = *assigneeID
+	}
+	if deadline != nil {
+		updates["deadline"] = *deadline
+	}
+	if len(updates) == 0 {
+		return ticket, nil
+	}
+	if userID > 0 {
+		updates["updated_by"] = userID
+	}
+	if err := database.DB.Model(&models.RectificationTicket{}).Where("id = ?", ticket.ID).
+		Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return GetRectificationTicketByID(ticket.ID)
+}
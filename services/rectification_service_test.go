@@ -0,0 +1,170 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"DLM_backend/models"
+
+	"gorm.io/gorm"
+)
+
+// createPinRecord 新建只填写栓销状况的点检记录，code 为 "ok" 或异常状态 "loose"
+func createPinRecord(t *testing.T, db *gorm.DB, code string) *models.InspectionRecord {
+	t.Helper()
+	unitID := 1
+	answers, _ := json.Marshal(map[string]models.ChecklistAnswer{"pin": {Value: json.RawMessage(`"` + code + `"`)}})
+	record := &models.InspectionRecord{
+		UserID:            10,
+		UnitID:            &unitID,
+		Unit:              "一库",
+		WarehouseNumber:   "1",
+		GrainDoorPosition: "东",
+		InspectionTime:    time.Now(),
+		Answers:           answers,
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error { return createRecordTx(tx, record) }); err != nil {
+		t.Fatalf("create record: %v", err)
+	}
+	return record
+}
+
+// fixedTestTicket 建立异常记录生成的整改工单，并推进到已整改状态
+func fixedTestTicket(t *testing.T, db *gorm.DB) *models.RectificationTicket {
+	t.Helper()
+	mustCreate(t, db, &models.ChecklistTemplate{Code: "test", Name: "测试模板", Version: 1, Active: true,
+		Items: []models.ChecklistItem{{Field: "pin", Label: "栓销状况", Normal: "ok", Options: []models.ChecklistOption{
+			{Code: "ok", Label: "正常"},
+			{Code: "loose", Label: "松动", Abnormal: true},
+		}}}})
+	mustCreate(t, db, &models.Unit{ID: 1, Name: "一库"})
+	record := createPinRecord(t, db, "loose")
+
+	var ticket models.RectificationTicket
+	if err := db.Where("record_id = ?", record.ID).First(&ticket).Error; err != nil {
+		t.Fatalf("find ticket: %v", err)
+	}
+	started, err := StartRectification(&ticket, 10, false)
+	if err != nil {
+		t.Fatalf("start rectification: %v", err)
+	}
+	mustCreate(t, db, &models.Attachment{URL: "/images/after.jpg", UploaderID: &started.Record.UserID})
+	fixed, err := FixRectification(started, []string{"/images/after.jpg"}, "已紧固", 10, false)
+	if err != nil {
+		t.Fatalf("fix rectification: %v", err)
+	}
+	return fixed
+}
+
+func TestVerifyRectification(t *testing.T) {
+	tests := []struct {
+		name         string
+		followUp     string // 复查点检记录中栓销状况的状态
+		wantStatus   string
+		wantReturned bool // 是否退回重新整改
+	}{
+		{name: "abnormality resolved", followUp: "ok", wantStatus: models.RectificationVerified},
+		{name: "abnormality still reported", followUp: "loose", wantStatus: models.RectificationInProgress, wantReturned: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			ticket := fixedTestTicket(t, db)
+			followUp := createPinRecord(t, db, tt.followUp)
+
+			updated, err := VerifyRectification(ticket, nil, 1)
+			if err != nil {
+				t.Fatalf("verify rectification: %v", err)
+			}
+			if updated.Status != tt.wantStatus {
+				t.Fatalf("status = %s, want %s", updated.Status, tt.wantStatus)
+			}
+			if updated.FollowUpRecordID == nil || *updated.FollowUpRecordID != followUp.ID {
+				t.Fatalf("follow up record = %v, want %d", updated.FollowUpRecordID, followUp.ID)
+			}
+			if !tt.wantReturned {
+				return
+			}
+			if updated.FixedAt != nil || updated.FixedBy != nil || len(updated.AfterImages) > 0 || updated.ReturnReason == "" {
+				t.Fatalf("returned ticket keeps the previous fix: %+v", updated)
+			}
+			// 退回后由责任人重新整改，已逾期的工单重新计入逾期
+			db.Model(&models.RectificationTicket{}).Where("id = ?", updated.ID).
+				Update("deadline", time.Now().Add(-time.Hour))
+			reloaded, err := GetRectificationTicketByID(updated.ID)
+			if err != nil {
+				t.Fatalf("reload ticket: %v", err)
+			}
+			if !reloaded.Overdue {
+				t.Fatalf("returned ticket past its deadline is not overdue")
+			}
+			if _, err := FixRectification(reloaded, []string{"/images/after.jpg"}, "重新紧固", 10, false); err != nil {
+				t.Fatalf("fix returned ticket: %v", err)
+			}
+		})
+	}
+}
+
+func TestRejectRectification(t *testing.T) {
+	db := setupTestDB(t)
+	ticket := fixedTestTicket(t, db)
+
+	if _, err := RejectRectification(ticket, "  ", 1); err == nil {
+		t.Fatalf("reject without reason: want error")
+	}
+	updated, err := RejectRectification(ticket, "照片不清晰", 1)
+	if err != nil {
+		t.Fatalf("reject rectification: %v", err)
+	}
+	if updated.Status != models.RectificationInProgress || updated.ReturnReason != "照片不清晰" || updated.FixedAt != nil {
+		t.Fatalf("rejected ticket = %+v", updated)
+	}
+	if _, err := RejectRectification(updated, "再次退回", 1); !errors.Is(err, ErrInvalidTicketTransition) {
+		t.Fatalf("reject in progress ticket: error = %v, want %v", err, ErrInvalidTicketTransition)
+	}
+}
+
+func TestFixRectificationImages(t *testing.T) {
+	db := setupTestDB(t)
+	ticket := fixedTestTicket(t, db)
+	ticket, err := RejectRectification(ticket, "重新整改", 1)
+	if err != nil {
+		t.Fatalf("reject rectification: %v", err)
+	}
+	otherUser := 11
+	mustCreate(t, db, &models.Attachment{URL: "/images/other.jpg", UploaderID: &otherUser})
+	mustCreate(t, db, &models.Attachment{URL: "/images/mine.jpg", UploaderID: &ticket.Record.UserID})
+
+	tests := []struct {
+		name    string
+		images  []string
+		wantErr bool
+	}{
+		{name: "no images", images: nil, wantErr: true},
+		{name: "blank images", images: []string{" ", ""}, wantErr: true},
+		{name: "unregistered url", images: []string{"https://example.com/a.jpg"}, wantErr: true},
+		{name: "uploaded by another user", images: []string{"/images/other.jpg"}, wantErr: true},
+		{name: "one of them not uploaded", images: []string{"/images/mine.jpg", "/images/missing.jpg"}, wantErr: true},
+		{name: "own uploads", images: []string{"/images/mine.jpg", " /images/after.jpg", "/images/mine.jpg"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixed, err := FixRectification(ticket, tt.images, "", 10, false)
+			if tt.wantErr {
+				var fieldErr *FieldError
+				if !errors.As(err, &fieldErr) || fieldErr.Field != "after_images" {
+					t.Fatalf("error = %v, want after_images field error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("fix rectification: %v", err)
+			}
+			if fixed.Status != models.RectificationFixed {
+				t.Fatalf("status = %s, want %s", fixed.Status, models.RectificationFixed)
+			}
+		})
+	}
+}
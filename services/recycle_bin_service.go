@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrRetentionNotExpired 回收站记录未超过保留时间，不能彻底删除
+var ErrRetentionNotExpired = errors.New("record is still within the retention period and cannot be purged")
+
+// recordRetention 删除的记录在回收站中的保留时间，0 表示随时可以彻底删除
+var recordRetention = 30 * 24 * time.Hour
+
+// SetRecordRetention 设置回收站记录的保留时间
+func SetRecordRetention(retention time.Duration) {
+	recordRetention = retention
+}
+
+// RecordRetention 返回回收站记录的保留时间
+func RecordRetention() time.Duration {
+	return recordRetention
+}
+
+// deletedRecords 回收站查询，只包含已删除的记录
+func deletedRecords(scope []int) *gorm.DB {
+	query := database.DB.Unscoped().Model(&models.InspectionRecord{}).Where("deleted_at IS NOT NULL")
+	if scope != nil {
+		query = query.Where("unit_id IN ?", scope)
+	}
+	return query
+}
+
+// GetDeletedRecords 分页获取回收站中的点检记录，按删除时间倒序
+func GetDeletedRecords(page, pageSize int, scope []int) (int64, []models.InspectionRecord, error) {
+	var records []models.InspectionRecord
+	var total int64
+
+	if err := deletedRecords(scope).Count(&total).Error; err != nil {
+		return 0, nil, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := deletedRecords(scope).Order("deleted_at DESC").
+		Offset(offset).Limit(pageSize).Find(&records).Error; err != nil {
+		return 0, nil, err
+	}
+	return total, records, nil
+}
+
+// getDeletedRecord 获取回收站中的单条记录
+func getDeletedRecord(id int, scope []int) (*models.InspectionRecord, error) {
+	var record models.InspectionRecord
+	if err := deletedRecords(nil).Where("id = ?", id).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	if err := CheckRecordInScope(&record, scope); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// RestoreInspectionRecord 从回收站恢复点检记录
+func RestoreInspectionRecord(id int, scope []int, userID int) (*models.InspectionRecord, error) {
+	record, err := getDeletedRecord(id, scope)
+	if err != nil {
+		return nil, err
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(record).
+			Updates(map[string]interface{}{"deleted_at": nil, "deleted_by": nil, "updated_by": userID}).Error; err != nil {
+			return err
+		}
+		return recordRevision(tx, record, models.RevisionActionRestore, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetInspectionRecordByID(id)
+}
+
+// PurgeInspectionRecord 彻底删除回收站中超过保留时间的点检记录
+func PurgeInspectionRecord(id int, scope []int) error {
+	record, err := getDeletedRecord(id, scope)
+	if err != nil {
+		return err
+	}
+	if time.Since(record.DeletedAt.Time) < recordRetention {
+		return ErrRetentionNotExpired
+	}
+	return database.DB.Unscoped().Delete(&models.InspectionRecord{}, id).Error
+}
+
+// PurgeExpiredRecords 彻底删除回收站中所有超过保留时间的点检记录，返回删除条数
+func PurgeExpiredRecords(scope []int) (int64, error) {
+	query := database.DB.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", time.Now().Add(-recordRetention))
+	if scope != nil {
+		query = query.Where("unit_id IN ?", scope)
+	}
+	result := query.Delete(&models.InspectionRecord{})
+	return result.RowsAffected, result.Error
+}
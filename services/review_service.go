@@ -0,0 +1,169 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotAwaitingReview 点检记录不在待审核状态
+var ErrNotAwaitingReview = errors.New("inspection record is not awaiting review")
+
+// ReviewFailure 批量审核中未能处理的记录
+type ReviewFailure struct {
+	ID    int    `json:"id"`    // 点检记录ID
+	Error string `json:"error"` // 失败原因
+}
+
+// copyReview 复制点检记录的审核状态
+func copyReview(dst, src *models.InspectionRecord) {
+	dst.ReviewStatus = src.ReviewStatus
+	dst.ReviewerID = src.ReviewerID
+	dst.ReviewedAt = src.ReviewedAt
+	dst.RejectReason = src.RejectReason
+}
+
+// markResubmitted 已驳回或已审核通过的记录内容被修改后，需要重新审核
+func markResubmitted(record *models.InspectionRecord) {
+	if record.ReviewStatus != models.ReviewRejected && record.ReviewStatus != models.ReviewApproved {
+		return
+	}
+	record.ReviewStatus = models.ReviewResubmitted
+	record.ReviewerID = nil
+	record.ReviewedAt = nil
+}
+
+// getReviewableRecord 获取用户可访问范围内等待审核的点检记录
+func getReviewableRecord(id int, scope []int) (*models.InspectionRecord, error) {
+	record, err := GetInspectionRecordByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckRecordInScope(record, scope); err != nil {
+		return nil, err
+	}
+	if !record.AwaitingReview() {
+		return nil, ErrNotAwaitingReview
+	}
+	return record, nil
+}
+
+// reviewRecord 写入审核结果，审核本身作为一个版本写入版本历史
+// 审核不是对记录内容的修改，只更新审核字段，不更新修改时间和修改人
+func reviewRecord(record *models.InspectionRecord, status, reason string, reviewerID int) (*models.InspectionRecord, error) {
+	action := models.RevisionActionApprove
+	if status == models.ReviewRejected {
+		action = models.RevisionActionReject
+	}
+
+	var saved models.InspectionRecord
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var previous models.InspectionRecord
+		if err := tx.First(&previous, record.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRecordNotFound
+			}
+			return err
+		}
+		if err := ensureBaseline(tx, &previous); err != nil {
+			return err
+		}
+
+		// 按读取时的审核状态更新，防止并发审核覆盖
+		result := tx.Model(&models.InspectionRecord{}).
+			Where("id = ? AND review_status = ?", record.ID, record.ReviewStatus).
+			UpdateColumns(map[string]interface{}{
+				"review_status": status,
+				"reviewer_id":   reviewerID,
+				"reviewed_at":   time.Now(),
+				"reject_reason": reason,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotAwaitingReview
+		}
+
+		if err := tx.First(&saved, record.ID).Error; err != nil {
+			return err
+		}
+		before, err := recordFields(&previous)
+		if err != nil {
+			return err
+		}
+		after, err := recordFields(&saved)
+		if err != nil {
+			return err
+		}
+		return appendRevision(tx, record.ID, action, reviewerID, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// ApproveInspectionRecord 审核通过点检记录
+func ApproveInspectionRecord(id int, scope []int, reviewerID int) (*models.InspectionRecord, error) {
+	record, err := getReviewableRecord(id, scope)
+	if err != nil {
+		return nil, err
+	}
+	return reviewRecord(record, models.ReviewApproved, "", reviewerID)
+}
+
+// RejectInspectionRecord 驳回点检记录，必须填写驳回原因
+func RejectInspectionRecord(id int, scope []int, reason string, reviewerID int) (*models.InspectionRecord, error) {
+	if reason == "" {
+		return nil, &FieldError{Field: "reason", Message: "reject reason is required"}
+	}
+	record, err := getReviewableRecord(id, scope)
+	if err != nil {
+		return nil, err
+	}
+	return reviewRecord(record, models.ReviewRejected, reason, reviewerID)
+}
+
+// BulkApproveInspectionRecords 批量审核通过点检记录，逐条处理，返回通过的记录ID和失败原因
+func BulkApproveInspectionRecords(ids []int, scope []int, reviewerID int) ([]int, []ReviewFailure) {
+	approved := make([]int, 0, len(ids))
+	failed := make([]ReviewFailure, 0)
+	for _, id := range ids {
+		if _, err := ApproveInspectionRecord(id, scope, reviewerID); err != nil {
+			failed = append(failed, ReviewFailure{ID: id, Error: err.Error()})
+			continue
+		}
+		approved = append(approved, id)
+	}
+	return approved, failed
+}
+
+// ReviewerNames 获取点检记录审核人的姓名，姓名为空时使用用户名
+func ReviewerNames(records []models.InspectionRecord) (map[int]string, error) {
+	var ids []int
+	for _, record := range records {
+		if record.ReviewerID != nil {
+			ids = append(ids, *record.ReviewerID)
+		}
+	}
+	names := make(map[int]string)
+	if len(ids) == 0 {
+		return names, nil
+	}
+	var users []models.User
+	if err := database.DB.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		names[user.ID] = user.Name
+		if user.Name == "" {
+			names[user.ID] = user.Username
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ErrRevisionNotFound 点检记录的指定版本不存在
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// snapshotIgnoredFields 不写入版本快照的字段（关联对象和回收站状态）
+var snapshotIgnoredFields = []string{"user", "reviewer", "deleted_at", "deleted_by"}
+
+// diffIgnoredFields 不参与比较的字段，这些字段在修改和回退时保持不变
+var diffIgnoredFields = map[string]bool{
+	"id": true, "user_id": true,
+	"created_at": true, "created_by": true, "updated_at": true, "updated_by": true, "version": true,
+}
+
+// recordFields 将点检记录转换为 字段名 -> 取值 的映射，字段名与接口 JSON 一致
+func recordFields(record *models.InspectionRecord) (map[string]interface{}, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for _, field := range snapshotIgnoredFields {
+		delete(fields, field)
+	}
+	return fields, nil
+}
+
+// diffRecordFields 比较两个版本的字段，按字段名排序返回变更列表
+func diffRecordFields(before, after map[string]interface{}) []models.FieldChange {
+	changes := []models.FieldChange{}
+	for field, newValue := range after {
+		if diffIgnoredFields[field] {
+			continue
+		}
+		if oldValue := before[field]; !reflect.DeepEqual(oldValue, newValue) {
+			changes = append(changes, models.FieldChange{Field: field, Old: oldValue, New: newValue})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// appendRevision 为点检记录追加一个版本，before 为 nil 时不计算字段变更
+func appendRevision(tx *gorm.DB, recordID int, action string, userID int, before, after map[string]interface{}) error {
+	var latest int
+	if err := tx.Model(&models.InspectionRevision{}).Where("record_id = ?", recordID).
+		Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+		return err
+	}
+
+	snapshot, err := json.Marshal(after)
+	if err != nil {
+		return err
+	}
+	var changes []byte
+	if before != nil {
+		if changes, err = json.Marshal(diffRecordFields(before, after)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Create(&models.InspectionRevision{
+		RecordID: recordID,
+		Version:  latest + 1,
+		Action:   action,
+		UserID:   userID,
+		Snapshot: datatypes.JSON(snapshot),
+		Changes:  datatypes.JSON(changes),
+	}).Error
+}
+
+// recordRevision 以记录的当前内容追加一个版本，用于新建、删除和恢复等不涉及字段修改的操作
+func recordRevision(tx *gorm.DB, record *models.InspectionRecord, action string, userID int) error {
+	fields, err := recordFields(record)
+	if err != nil {
+		return err
+	}
+	return appendRevision(tx, record.ID, action, userID, nil, fields)
+}
+
+// ensureBaseline 为没有任何版本的历史记录补记原始版本，保证修改前的内容可以回退
+func ensureBaseline(tx *gorm.DB, record *models.InspectionRecord) error {
+	var count int64
+	if err := tx.Model(&models.InspectionRevision{}).Where("record_id = ?", record.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return recordRevision(tx, record, models.RevisionActionBaseline, record.UserID)
+}
+
+// saveRecordWithRevision 保存点检记录的修改并追加带字段变更的版本
+func saveRecordWithRevision(record *models.InspectionRecord, action string, userID int) (*models.InspectionRecord, error) {
+	var saved *models.InspectionRecord
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		saved, err = saveRecordTx(tx, record, action, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// saveRecordTx 在事务中保存点检记录的修改并追加带字段变更的版本
+func saveRecordTx(tx *gorm.DB, record *models.InspectionRecord, action string, userID int) (*models.InspectionRecord, error) {
+	var previous models.InspectionRecord
+	if err := tx.First(&previous, record.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	if err := ensureBaseline(tx, &previous); err != nil {
+		return nil, err
+	}
+
+	record.SetUpdater(userID)
+	if err := tx.Omit("user_id", "Reviewer", "created_at", "created_by", "deleted_at", "deleted_by", "version").Save(record).Error; err != nil {
+		return nil, err
+	}
+	// 版本号按读取时的值递增，期间被其他请求修改过时放弃保存
+	result := tx.Model(&models.InspectionRecord{}).Where("id = ? AND version = ?", record.ID, previous.Version).
+		UpdateColumn("version", gorm.Expr("version + 1"))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrRecordModified
+	}
+
+	// 重新读取保存后的记录，保证前后两个版本的字段格式一致
+	var saved models.InspectionRecord
+	if err := tx.First(&saved, record.ID).Error; err != nil {
+		return nil, err
+	}
+
+	before, err := recordFields(&previous)
+	if err != nil {
+		return nil, err
+	}
+	after, err := recordFields(&saved)
+	if err != nil {
+		return nil, err
+	}
+	if err := appendRevision(tx, record.ID, action, userID, before, after); err != nil {
+		return nil, err
+	}
+	if err := linkRecordAttachments(tx, &saved, userID); err != nil {
+		return nil, err
+	}
+	// 修改或回退后新出现的异常项同样生成整改工单
+	if err := openRectificationTickets(tx, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// GetRecordRevisions 获取点检记录的全部版本，按版本号升序
+func GetRecordRevisions(recordID int) ([]models.InspectionRevision, error) {
+	var revisions []models.InspectionRevision
+	if err := database.DB.Preload("User").Where("record_id = ?", recordID).
+		Order("version ASC").Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// RevertInspectionRecord 将点检记录回退到指定版本的点检内容，回退本身也会生成一个新版本
+// 单位、挡粮门、扫码结果和模板版本保持当前值，回退的内容不符合模板要求时返回 *FieldError
+func RevertInspectionRecord(record *models.InspectionRecord, version int, userID int) (*models.InspectionRecord, error) {
+	var revision models.InspectionRevision
+	if err := database.DB.Where("record_id = ? AND version = ?", record.ID, version).
+		First(&revision).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRevisionNotFound
+		}
+		return nil, err
+	}
+
+	var target models.InspectionRecord
+	if err := json.Unmarshal(revision.Snapshot, &target); err != nil {
+		return nil, err
+	}
+
+	// 回退只恢复点检内容，记录归属、提交时间和审核状态保持不变，回退后需要重新审核
+	target.ID = record.ID
+	target.UserID = record.UserID
+	target.Audit = record.Audit
+	copyReview(&target, record)
+	// 单位是访问范围的依据，挡粮门和扫码结果随单位确定，回退不能把记录移到其他单位或挡粮门
+	target.UnitID = record.UnitID
+	target.Unit = record.Unit
+	target.GrainDoorID = record.GrainDoorID
+	target.WarehouseNumber = record.WarehouseNumber
+	target.GrainDoorPosition = record.GrainDoorPosition
+	target.QRVerified = record.QRVerified
+	target.QRVersion = record.QRVersion
+	target.QRTokenHash = record.QRTokenHash
+	// 记录保持原有的模板版本，回退的内容按该版本重新校验
+	target.TemplateID = record.TemplateID
+	if err := ValidateRecordChecklist(&target); err != nil {
+		return nil, err
+	}
+	markResubmitted(&target)
+	return saveRecordWithRevision(&target, models.RevisionActionRevert, userID)
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"DLM_backend/models"
+
+	"gorm.io/gorm"
+)
+
+func TestRevertInspectionRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(t *testing.T, db *gorm.DB, recordID int) // 修改要回退到的版本快照
+		wantErr bool
+	}{
+		{name: "restores content only"},
+		{name: "snapshot no longer valid", wantErr: true,
+			tamper: func(t *testing.T, db *gorm.DB, recordID int) {
+				if err := db.Model(&models.InspectionRevision{}).Where("record_id = ? AND version = ?", recordID, 2).
+					Update("snapshot", gorm.Expr("REPLACE(snapshot, ?, ?)", `"ok"`, `"broken"`)).Error; err != nil {
+					t.Fatalf("tamper snapshot: %v", err)
+				}
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			mustCreate(t, db, &models.ChecklistTemplate{Code: "test", Name: "测试模板", Version: 1, Active: true,
+				Items: []models.ChecklistItem{{Field: "pin", Label: "栓销状况", Normal: "ok", Options: []models.ChecklistOption{
+					{Code: "ok", Label: "正常"},
+					{Code: "loose", Label: "松动", Abnormal: true},
+				}}}})
+			mustCreate(t, db, &models.Unit{ID: 1, Name: "一库"})
+			mustCreate(t, db, &models.Unit{ID: 2, Name: "二库"})
+			mustCreate(t, db, &models.Warehouse{ID: 1, UnitID: 2, Number: "2", Name: "2号仓"})
+			mustCreate(t, db, &models.GrainDoor{ID: 1, WarehouseID: 1, Position: "西"})
+			record := createPinRecord(t, db, "ok")
+			record.Remarks = "第二版"
+			if _, err := UpdateInspectionRecord(record, 10); err != nil {
+				t.Fatalf("update record: %v", err)
+			}
+
+			// 第三版移到单位2的挡粮门并标记为扫码点检
+			current, err := GetInspectionRecordByID(record.ID)
+			if err != nil {
+				t.Fatalf("get record: %v", err)
+			}
+			unitID, doorID := 2, 1
+			current.UnitID, current.Unit, current.GrainDoorID = &unitID, "二库", &doorID
+			current.WarehouseNumber, current.GrainDoorPosition = "2", "西"
+			current.SetQRScan("dlm:door:1:1:sig", 1)
+			current.Remarks = "第三版"
+			if current, err = UpdateInspectionRecord(current, 10); err != nil {
+				t.Fatalf("move record: %v", err)
+			}
+			if tt.tamper != nil {
+				tt.tamper(t, db, record.ID)
+			}
+
+			reverted, err := RevertInspectionRecord(current, 2, 1)
+			if tt.wantErr {
+				var fieldErr *FieldError
+				if !errors.As(err, &fieldErr) {
+					t.Fatalf("error = %v, want field error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("revert record: %v", err)
+			}
+			if reverted.Remarks != "第二版" {
+				t.Fatalf("remarks = %q, want the second version's content", reverted.Remarks)
+			}
+			if reverted.UnitID == nil || *reverted.UnitID != 2 || reverted.Unit != "二库" ||
+				reverted.GrainDoorID == nil || *reverted.GrainDoorID != 1 || reverted.WarehouseNumber != "2" {
+				t.Fatalf("revert moved the record: unit %v %q door %v warehouse %q",
+					reverted.UnitID, reverted.Unit, reverted.GrainDoorID, reverted.WarehouseNumber)
+			}
+			if !reverted.QRVerified || reverted.QRTokenHash != current.QRTokenHash {
+				t.Fatalf("revert cleared the current qr scan")
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrRoleNotFound 角色不存在
+var ErrRoleNotFound = errors.New("role not found")
+
+// ErrRoleExists 角色名称已存在
+var ErrRoleExists = errors.New("role already exists")
+
+// ErrRoleInUse 角色仍有用户使用或为内置角色，不能删除
+var ErrRoleInUse = errors.New("role is builtin or still assigned to users")
+
+// ErrUnknownPermission 权限编码不存在
+var ErrUnknownPermission = errors.New("unknown permission")
+
+// RoleExists 判断角色是否存在
+func RoleExists(name string) bool {
+	var count int64
+	if err := database.DB.Model(&models.Role{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return false
+	}
+	return count > 0
+}
+
+// GetRoles 获取所有角色及其权限
+func GetRoles() ([]models.Role, error) {
+	var roles []models.Role
+	if err := database.DB.Preload("Permissions").Order("id").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// GetPermissions 获取所有权限
+func GetPermissions() ([]models.Permission, error) {
+	var permissions []models.Permission
+	if err := database.DB.Order("id").Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// GetRoleByID 根据ID获取角色及其权限
+func GetRoleByID(id int) (*models.Role, error) {
+	var role models.Role
+	if err := database.DB.Preload("Permissions").First(&role, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, err
+	}
+	return &role, nil
+}
+
+// findPermissions 根据权限编码查询权限，存在未知编码时返回错误
+func findPermissions(codes []string) ([]models.Permission, error) {
+	var permissions []models.Permission
+	if len(codes) == 0 {
+		return permissions, nil
+	}
+	if err := database.DB.Where("code IN ?", codes).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool)
+	for _, permission := range permissions {
+		found[permission.Code] = true
+	}
+	for _, code := range codes {
+		if !found[code] {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownPermission, code)
+		}
+	}
+	return permissions, nil
+}
+
+// CreateRole 新建角色并授予权限
+func CreateRole(role *models.Role, permissionCodes []string) (*models.Role, error) {
+	if RoleExists(role.Name) {
+		return nil, ErrRoleExists
+	}
+	permissions, err := findPermissions(permissionCodes)
+	if err != nil {
+		return nil, err
+	}
+	role.Permissions = permissions
+	if err := database.DB.Create(role).Error; err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// UpdateRole 更新角色显示名称，并在 permissionCodes 不为 nil 时替换其权限
+func UpdateRole(role *models.Role, permissionCodes []string) (*models.Role, error) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(role).Updates(map[string]interface{}{
+			"display_name": role.DisplayName,
+			"updated_by":   role.UpdatedBy,
+		}).Error; err != nil {
+			return err
+		}
+		if permissionCodes == nil {
+			return nil
+		}
+		permissions, err := findPermissions(permissionCodes)
+		if err != nil {
+			return err
+		}
+		return tx.Model(role).Association("Permissions").Replace(permissions)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetRoleByID(role.ID)
+}
+
+// DeleteRole 删除非内置且无用户使用的角色
+func DeleteRole(id int) error {
+	role, err := GetRoleByID(id)
+	if err != nil {
+		return err
+	}
+	if role.Builtin {
+		return ErrRoleInUse
+	}
+
+	var count int64
+	if err := database.DB.Model(&models.User{}).Where("role = ?", role.Name).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrRoleInUse
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+}
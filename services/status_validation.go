@@ -0,0 +1,172 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+)
+
+// FieldError 某个字段校验失败，控制器返回带 field 的 400 响应
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidateRecordChecklist 按点检记录使用的模板版本校验各点检项，返回第一个不合法字段的 *FieldError
+// 记录未指定模板时使用当前启用的模板；未按模板提交 answers 时由固定字段生成。
+// 校验通过后写入模板版本和规范化的答案，并同步默认模板对应的固定字段
+func ValidateRecordChecklist(record *models.InspectionRecord) error {
+	template, err := recordTemplate(database.DB, record)
+	if err != nil {
+		return err
+	}
+
+	answers, err := record.ChecklistAnswers()
+	if err != nil {
+		return &FieldError{Field: "answers", Message: "must be an object keyed by check item"}
+	}
+	if len(answers) == 0 {
+		for field, answer := range record.LegacyAnswers() {
+			if _, ok := template.FindItem(field); ok {
+				answers[field] = answer
+			}
+		}
+	}
+
+	for field := range answers {
+		if _, ok := template.FindItem(field); !ok {
+			return &FieldError{Field: field, Message: fmt.Sprintf("unknown check item in template %s v%d", template.Code, template.Version)}
+		}
+	}
+	recordPhotos := countImages(record.Images)
+	for _, item := range template.Items {
+		answer := answers[item.Field]
+		answer.Images = compactImages(answer.Images)
+		codes, err := validateAnswer(item, answer, recordPhotos)
+		if err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			delete(answers, item.Field)
+			continue
+		}
+		answer.Description = strings.TrimSpace(answer.Description)
+		if item.Multiple {
+			answer.Value, _ = json.Marshal(codes)
+		} else {
+			answer.Value, _ = json.Marshal(codes[0])
+		}
+		answers[item.Field] = answer
+	}
+
+	answersJSON, _ := json.Marshal(answers)
+	record.TemplateID = &template.ID
+	record.Answers = answersJSON
+	record.SetLegacyColumns(answers)
+	return nil
+}
+
+// validateAnswer 校验单个点检项的答案，返回选择的状态编码
+// 单选只能选择一个状态；多选编码不能重复，"正常"不能与异常状态同时选择；
+// 选择异常状态时按模板要求填写说明并上传照片，点检项没有单独的照片时按记录的图片列表计算（按固定字段提交的客户端）
+func validateAnswer(item models.ChecklistItem, answer models.ChecklistAnswer, recordPhotos int) ([]string, error) {
+	codes, ok := answer.Codes()
+	if !ok {
+		if item.Multiple {
+			return nil, &FieldError{Field: item.Field, Message: "must be an array of status codes"}
+		}
+		return nil, &FieldError{Field: item.Field, Message: "must be a status code"}
+	}
+	if len(codes) == 0 {
+		if item.Required {
+			if item.Multiple {
+				return nil, &FieldError{Field: item.Field, Message: "at least one status is required"}
+			}
+			return nil, &FieldError{Field: item.Field, Message: "is required"}
+		}
+		return nil, nil
+	}
+	if !item.Multiple && len(codes) > 1 {
+		return nil, &FieldError{Field: item.Field, Message: "only one status can be selected"}
+	}
+
+	seen := make(map[string]bool, len(codes))
+	abnormal := false
+	for _, code := range codes {
+		if !item.Allows(code) {
+			return nil, &FieldError{
+				Field:   item.Field,
+				Message: fmt.Sprintf("invalid status %q, allowed: %s", code, strings.Join(item.Codes(), ", ")),
+			}
+		}
+		if seen[code] {
+			return nil, &FieldError{Field: item.Field, Message: fmt.Sprintf("duplicate status %q", code)}
+		}
+		seen[code] = true
+		abnormal = abnormal || item.IsAbnormal(code)
+	}
+
+	if item.Normal != "" && seen[item.Normal] && len(codes) > 1 {
+		return nil, &FieldError{
+			Field:   item.Field,
+			Message: fmt.Sprintf("%q cannot be combined with other statuses", item.Normal),
+		}
+	}
+	if !abnormal {
+		return codes, nil
+	}
+	if item.DescriptionRequired && strings.TrimSpace(answer.Description) == "" {
+		return nil, &FieldError{Field: item.Field, Message: "description is required for abnormal status"}
+	}
+	photos := len(answer.Images)
+	if photos == 0 {
+		photos = recordPhotos
+	}
+	if photos < item.MinPhotos {
+		return nil, &FieldError{
+			Field:   item.Field,
+			Message: fmt.Sprintf("at least %d photo(s) are required for abnormal status", item.MinPhotos),
+		}
+	}
+	return codes, nil
+}
+
+// compactImages 去掉空的图片路径
+func compactImages(images []string) []string {
+	var result []string
+	for _, image := range images {
+		if image = strings.TrimSpace(image); image != "" {
+			result = append(result, image)
+		}
+	}
+	return result
+}
+
+// countImages 统计记录图片列表中的照片数，无法解析时视为没有照片
+func countImages(raw []byte) int {
+	var images []string
+	if err := json.Unmarshal(raw, &images); err != nil {
+		return 0
+	}
+	return len(compactImages(images))
+}
+
+// AnswerLabels 将点检项答案的状态编码转换为显示名称，多选以逗号分隔，无法解析时返回原始值
+func AnswerLabels(item models.ChecklistItem, answer models.ChecklistAnswer) string {
+	codes, ok := answer.Codes()
+	if !ok {
+		return string(answer.Value)
+	}
+	labels := make([]string, 0, len(codes))
+	for _, code := range codes {
+		labels = append(labels, item.LabelOf(code))
+	}
+	return strings.Join(labels, ",")
+}
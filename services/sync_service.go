@@ -0,0 +1,113 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrSyncConflict 客户端离线期间服务端的记录已被修改或删除
+var ErrSyncConflict = errors.New("record was changed on the server after the client last synced")
+
+// FindSyncReceipt 查找用户已处理过的幂等键，未处理过时返回 nil
+func FindSyncReceipt(userID int, key string) (*models.SyncReceipt, error) {
+	var receipt models.SyncReceipt
+	if err := database.DB.Where("user_id = ? AND idempotency_key = ?", userID, key).
+		First(&receipt).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &receipt, nil
+}
+
+// createSyncReceipt 在事务中登记已处理的幂等键，并发重复提交时由唯一索引保证只成功一次
+func createSyncReceipt(tx *gorm.DB, userID int, key string, recordID int, action string) error {
+	return tx.Create(&models.SyncReceipt{
+		UserID:         userID,
+		IdempotencyKey: key,
+		RecordID:       recordID,
+		Action:         action,
+	}).Error
+}
+
+// SyncCreateRecord 新建离线采集的点检记录，记录和幂等键在同一事务中保存
+func SyncCreateRecord(record *models.InspectionRecord, key string) (*models.InspectionRecord, error) {
+	if err := ValidateRecordChecklist(record); err != nil {
+		return nil, err
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := createRecordTx(tx, record); err != nil {
+			return err
+		}
+		return createSyncReceipt(tx, record.UserID, key, record.ID, models.SyncActionCreated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// GetRecordForSync 获取离线修改的目标记录，并检查访问范围和修改权限，记录已移入回收站时返回冲突
+// 范围和权限检查先于冲突判断，无权访问的记录不会作为冲突时的服务端记录返回给客户端
+func GetRecordForSync(id int, scope []int, userID int, canUpdateAny bool) (*models.InspectionRecord, error) {
+	var record models.InspectionRecord
+	if err := database.DB.Unscoped().First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	if err := CheckRecordInScope(&record, scope); err != nil {
+		return nil, err
+	}
+	if err := CheckRecordModifiable(&record, userID, canUpdateAny); err != nil {
+		return nil, err
+	}
+	if record.DeletedAt.Valid {
+		return &record, fmt.Errorf("%w: record has been deleted", ErrSyncConflict)
+	}
+	return &record, nil
+}
+
+// SyncUpdateRecord 保存离线修改的点检记录
+// baseVersion 为客户端修改前看到的记录版本号，与服务端不一致时说明期间有其他修改，返回冲突和服务端当前记录
+func SyncUpdateRecord(record *models.InspectionRecord, baseVersion int, key string, userID int) (*models.InspectionRecord, error) {
+	if err := ValidateRecordChecklist(record); err != nil {
+		return nil, err
+	}
+	markResubmitted(record)
+
+	var saved, current *models.InspectionRecord
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var latest models.InspectionRecord
+		if err := tx.First(&latest, record.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: record has been deleted", ErrSyncConflict)
+			}
+			return err
+		}
+		if latest.Version != baseVersion {
+			current = &latest
+			return ErrSyncConflict
+		}
+
+		var err error
+		if saved, err = saveRecordTx(tx, record, models.RevisionActionUpdate, userID); err != nil {
+			if errors.Is(err, ErrRecordModified) {
+				return fmt.Errorf("%w: %v", ErrSyncConflict, err)
+			}
+			return err
+		}
+		return createSyncReceipt(tx, userID, key, record.ID, models.SyncActionUpdated)
+	})
+	if err != nil {
+		return current, err
+	}
+	return saved, nil
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"DLM_backend/models"
+
+	"gorm.io/gorm"
+)
+
+// createTestRecord 以 userID 的身份在 unitID 下新建点检记录
+func createTestRecord(t *testing.T, db *gorm.DB, unitID, userID int) *models.InspectionRecord {
+	t.Helper()
+	record := &models.InspectionRecord{
+		UserID:            userID,
+		UnitID:            &unitID,
+		Unit:              "一库",
+		WarehouseNumber:   "1",
+		GrainDoorPosition: "东",
+		InspectionTime:    time.Now(),
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error { return createRecordTx(tx, record) }); err != nil {
+		t.Fatalf("create record: %v", err)
+	}
+	return record
+}
+
+// createTestTemplate 建立只有一个选填点检项的启用模板
+func createTestTemplate(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	mustCreate(t, db, &models.ChecklistTemplate{Code: "test", Name: "测试模板", Version: 1, Active: true,
+		Items: []models.ChecklistItem{{Field: "remarks_check", Label: "备注", Options: []models.ChecklistOption{{Code: "ok", Label: "正常"}}}}})
+}
+
+// seedSyncTestData 建立两个单位及其中的点检记录，返回各场景使用的记录
+// live 为单位1中用户10的正常记录，deleted 为单位1中用户10已移入回收站的记录，
+// otherUnitDeleted 为单位2中用户12已移入回收站的记录
+func seedSyncTestData(t *testing.T, db *gorm.DB) (live, deleted, otherUnitDeleted *models.InspectionRecord) {
+	t.Helper()
+	createTestTemplate(t, db)
+	mustCreate(t, db, &models.Unit{ID: 1, Name: "一库"})
+	mustCreate(t, db, &models.Unit{ID: 2, Name: "二库"})
+	live = createTestRecord(t, db, 1, 10)
+	deleted = createTestRecord(t, db, 1, 10)
+	otherUnitDeleted = createTestRecord(t, db, 2, 12)
+	for _, record := range []*models.InspectionRecord{deleted, otherUnitDeleted} {
+		if err := db.Delete(record).Error; err != nil {
+			t.Fatalf("delete record: %v", err)
+		}
+	}
+	return live, deleted, otherUnitDeleted
+}
+
+func TestGetRecordForSync(t *testing.T) {
+	db := setupTestDB(t)
+	live, deleted, otherUnitDeleted := seedSyncTestData(t, db)
+
+	tests := []struct {
+		name         string
+		id           int
+		scope        []int
+		userID       int
+		canUpdateAny bool
+		wantErr      error
+		wantRecord   bool // 是否返回服务端记录
+	}{
+		{name: "own record", id: live.ID, scope: []int{1}, userID: 10, wantRecord: true},
+		{name: "missing record", id: 999, scope: []int{1}, userID: 10, wantErr: ErrRecordNotFound},
+		{name: "live record in another unit", id: live.ID, scope: []int{2}, userID: 10, wantErr: ErrRecordNotFound},
+		{name: "deleted record in another unit", id: otherUnitDeleted.ID, scope: []int{1}, userID: 10, wantErr: ErrRecordNotFound},
+		{name: "deleted record in another unit with update any", id: otherUnitDeleted.ID, scope: []int{1}, userID: 10,
+			canUpdateAny: true, wantErr: ErrRecordNotFound},
+		{name: "record of another user", id: live.ID, scope: []int{1}, userID: 11, wantErr: ErrRecordNotOwned},
+		{name: "deleted record of another user", id: deleted.ID, scope: []int{1}, userID: 11, wantErr: ErrRecordNotOwned},
+		{name: "own deleted record", id: deleted.ID, scope: []int{1}, userID: 10, wantErr: ErrSyncConflict, wantRecord: true},
+		{name: "deleted record with unrestricted scope", id: otherUnitDeleted.ID, scope: nil, userID: 1,
+			canUpdateAny: true, wantErr: ErrSyncConflict, wantRecord: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := GetRecordForSync(tt.id, tt.scope, tt.userID, tt.canUpdateAny)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantRecord {
+				if record == nil || record.ID != tt.id {
+					t.Fatalf("record = %+v, want record %d", record, tt.id)
+				}
+			} else if record != nil {
+				t.Fatalf("record %d returned, want nil", record.ID)
+			}
+		})
+	}
+}
+
+func TestSyncUpdateRecord(t *testing.T) {
+	tests := []struct {
+		name        string
+		prepare     func(t *testing.T, record *models.InspectionRecord) // 客户端离线期间服务端的操作
+		baseVersion int
+		wantErr     error
+		wantVersion int // 保存成功时的新版本号，冲突时服务端记录的版本号
+	}{
+		{name: "unchanged", baseVersion: 1, wantVersion: 2},
+		{name: "stale base version", baseVersion: 1, wantErr: ErrSyncConflict, wantVersion: 2,
+			prepare: func(t *testing.T, record *models.InspectionRecord) {
+				record.Remarks = "在线修改"
+				if _, err := UpdateInspectionRecord(record, 10); err != nil {
+					t.Fatalf("update record: %v", err)
+				}
+			}},
+		{name: "base version ahead of server", baseVersion: 5, wantErr: ErrSyncConflict, wantVersion: 1},
+		{name: "review does not bump version", baseVersion: 1, wantVersion: 2,
+			prepare: func(t *testing.T, record *models.InspectionRecord) {
+				if _, err := ApproveInspectionRecord(record.ID, nil, 1); err != nil {
+					t.Fatalf("approve record: %v", err)
+				}
+			}},
+		{name: "deleted on server", baseVersion: 1, wantErr: ErrSyncConflict,
+			prepare: func(t *testing.T, record *models.InspectionRecord) {
+				if err := DeleteInspectionRecord(record.ID, 10); err != nil {
+					t.Fatalf("delete record: %v", err)
+				}
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			createTestTemplate(t, db)
+			mustCreate(t, db, &models.Unit{ID: 1, Name: "一库"})
+			record := createTestRecord(t, db, 1, 10)
+			if tt.prepare != nil {
+				server := *record
+				tt.prepare(t, &server)
+			}
+
+			offline := *record
+			offline.Remarks = "离线修改"
+			saved, err := SyncUpdateRecord(&offline, tt.baseVersion, "key-"+tt.name, 10)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantVersion == 0 {
+				return
+			}
+			if saved == nil {
+				t.Fatalf("no record returned, want version %d", tt.wantVersion)
+			}
+			if saved.Version != tt.wantVersion {
+				t.Fatalf("version = %d, want %d", saved.Version, tt.wantVersion)
+			}
+			if tt.wantErr == nil && saved.Remarks != "离线修改" {
+				t.Fatalf("remarks = %q, offline change was not saved", saved.Remarks)
+			}
+			if tt.wantErr != nil && saved.Remarks == "离线修改" {
+				t.Fatalf("conflicting offline change was saved")
+			}
+
+			receipt, err := FindSyncReceipt(10, "key-"+tt.name)
+			if err != nil {
+				t.Fatalf("find receipt: %v", err)
+			}
+			if (receipt != nil) != (tt.wantErr == nil) {
+				t.Fatalf("receipt = %+v, want receipt only when saved", receipt)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB 为测试建立独立的 SQLite 数据库并替换全局数据库实例，测试结束后恢复
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&models.User{},
+		&models.Unit{},
+		&models.InspectionRecord{},
+		&models.InspectionRevision{},
+		&models.Warehouse{},
+		&models.GrainDoor{},
+		&models.InspectionPlan{},
+		&models.RectificationTicket{},
+		&models.SyncReceipt{},
+		&models.ChecklistTemplate{},
+		&models.Attachment{},
+	); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// mustCreate 写入测试数据，失败时终止测试
+func mustCreate(t *testing.T, db *gorm.DB, value interface{}) {
+	t.Helper()
+	if err := db.Create(value).Error; err != nil {
+		t.Fatalf("create %T: %v", value, err)
+	}
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+	"DLM_backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidRefreshToken 刷新令牌无效或已过期
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+// ErrRefreshTokenReused 已轮换的刷新令牌被再次使用，疑似被盗用
+var ErrRefreshTokenReused = errors.New("refresh token reuse detected, please log in again")
+
+// refreshTokenTTL 刷新令牌有效期，可以通过 SetRefreshTokenTTL 覆盖
+var refreshTokenTTL = 30 * 24 * time.Hour
+
+// SetRefreshTokenTTL 设置刷新令牌有效期
+func SetRefreshTokenTTL(ttl time.Duration) {
+	refreshTokenTTL = ttl
+}
+
+// TokenPair 登录或刷新后返回给客户端的令牌对
+type TokenPair struct {
+	AccessToken  string `json:"token"`         // 访问令牌，沿用原有的 token 字段名
+	RefreshToken string `json:"refresh_token"` // 刷新令牌
+	ExpiresIn    int64  `json:"expires_in"`    // 访问令牌有效期（秒）
+}
+
+// ClientInfo 签发令牌时记录的客户端信息
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// hashRefreshToken 计算刷新令牌的哈希值，数据库中不保存令牌原文
+func hashRefreshToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueTokens 为用户签发访问令牌和新的刷新令牌（开启新的令牌族）
+func IssueTokens(user *models.User, client ClientInfo) (*TokenPair, error) {
+	familyID, err := utils.RandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	pair, _, err := issueTokensInFamily(database.DB, user, familyID, client)
+	return pair, err
+}
+
+// issueTokensInFamily 在指定令牌族中签发令牌对，返回新刷新令牌的记录
+func issueTokensInFamily(tx *gorm.DB, user *models.User, familyID string, client ClientInfo) (*TokenPair, *models.RefreshToken, error) {
+	accessToken, err := utils.GenerateToken(user.ID, user.Username, user.Role, user.TokenVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	raw, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, nil, err
+	}
+	refresh := models.RefreshToken{
+		UserID:       user.ID,
+		TokenHash:    hashRefreshToken(raw),
+		FamilyID:     familyID,
+		TokenVersion: user.TokenVersion,
+		ExpiresAt:    time.Now().Add(refreshTokenTTL),
+		UserAgent:    client.UserAgent,
+		IP:           client.IP,
+	}
+	if err := tx.Create(&refresh).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: raw,
+		ExpiresIn:    int64(utils.AccessTokenTTL().Seconds()),
+	}, &refresh, nil
+}
+
+// RefreshTokens 使用刷新令牌换取新的令牌对，旧的刷新令牌随即失效
+// 如果已经轮换过的刷新令牌被再次使用，则吊销整个令牌族并使该用户的访问令牌全部失效
+func RefreshTokens(raw string, client ClientInfo) (*TokenPair, error) {
+	var pair *TokenPair
+	var reused bool
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		if err := tx.Where("token_hash = ?", hashRefreshToken(raw)).First(&current).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		var user models.User
+		if err := tx.First(&user, current.UserID).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		// 重放检测：已经轮换过的令牌再次出现，说明令牌可能已泄露
+		if current.RevokedAt != nil && current.ReplacedByID != nil {
+			reused = true
+			now := time.Now()
+			if err := tx.Model(&models.RefreshToken{}).
+				Where("family_id = ? AND revoked_at IS NULL", current.FamilyID).
+				Update("revoked_at", now).Error; err != nil {
+				return err
+			}
+			return tx.Model(&user).Update("token_version", gorm.Expr("token_version + 1")).Error
+		}
+
+		if current.RevokedAt != nil || time.Now().After(current.ExpiresAt) || user.Disabled || current.TokenVersion != user.TokenVersion {
+			return ErrInvalidRefreshToken
+		}
+
+		// 轮换：签发同一令牌族中的新令牌，并吊销当前令牌
+		newPair, next, err := issueTokensInFamily(tx, &user, current.FamilyID, client)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := tx.Model(&current).Updates(map[string]interface{}{
+			"revoked_at":     now,
+			"replaced_by_id": next.ID,
+		}).Error; err != nil {
+			return err
+		}
+		pair = newPair
+		return nil
+	})
+
+	if reused {
+		log.Printf("refresh token reuse detected, token family revoked")
+		return nil, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Logout 退出登录：吊销当前访问令牌，并吊销所提供刷新令牌所在的令牌族
+func Logout(user *models.User, jti string, accessExpiresAt time.Time, rawRefreshToken string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if jti != "" {
+			revoked := models.RevokedToken{JTI: jti, ExpiresAt: accessExpiresAt}
+			if err := tx.Where(models.RevokedToken{JTI: jti}).FirstOrCreate(&revoked).Error; err != nil {
+				return err
+			}
+		}
+
+		if rawRefreshToken == "" {
+			return nil
+		}
+		var refresh models.RefreshToken
+		if err := tx.Where("token_hash = ? AND user_id = ?", hashRefreshToken(rawRefreshToken), user.ID).
+			First(&refresh).Error; err != nil {
+			// 刷新令牌不存在或不属于当前用户时忽略，访问令牌已吊销
+			return nil
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", refresh.FamilyID).
+			Update("revoked_at", time.Now()).Error
+	})
+}
+
+// PurgeExpiredTokens 清理已经过期的吊销记录和刷新令牌
+func PurgeExpiredTokens() error {
+	now := time.Now()
+	if err := database.DB.Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+		return err
+	}
+	return database.DB.Where("expires_at < ?", now).Delete(&models.RefreshToken{}).Error
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrUnitNotFound 单位不存在
+var ErrUnitNotFound = errors.New("unit not found")
+
+// ErrUnitExists 单位名称已存在
+var ErrUnitExists = errors.New("unit already exists")
+
+// ErrUnitInUse 单位下仍有用户、仓房或点检记录，不能删除
+var ErrUnitInUse = errors.New("unit still has users, warehouses or inspection records")
+
+// ErrUnitNotAccessible 当前用户不属于该单位
+var ErrUnitNotAccessible = errors.New("unit is not assigned to you")
+
+// GetUnits 获取单位列表，scope 不为 nil 时只返回其中的单位
+func GetUnits(scope []int) ([]models.Unit, error) {
+	var units []models.Unit
+	query := database.DB.Order("id")
+	if scope != nil {
+		query = query.Where("id IN ?", scope)
+	}
+	if err := query.Find(&units).Error; err != nil {
+		return nil, err
+	}
+	return units, nil
+}
+
+// GetUnitByID 根据ID获取单位
+func GetUnitByID(id int) (*models.Unit, error) {
+	var unit models.Unit
+	if err := database.DB.First(&unit, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUnitNotFound
+		}
+		return nil, err
+	}
+	return &unit, nil
+}
+
+// unitNameTaken 判断单位名称是否已被其他单位使用
+func unitNameTaken(name string, excludeID int) (bool, error) {
+	var count int64
+	if err := database.DB.Model(&models.Unit{}).
+		Where("name = ? AND id <> ?", name, excludeID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CreateUnit 新建单位
+func CreateUnit(unit *models.Unit) (*models.Unit, error) {
+	taken, err := unitNameTaken(unit.Name, 0)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, ErrUnitExists
+	}
+	if err := database.DB.Create(unit).Error; err != nil {
+		return nil, err
+	}
+	return unit, nil
+}
+
+// UpdateUnit 更新单位信息，单位改名时同步更新点检记录上的单位名称
+func UpdateUnit(unit *models.Unit) (*models.Unit, error) {
+	taken, err := unitNameTaken(unit.Name, unit.ID)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, ErrUnitExists
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(unit).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&models.InspectionRecord{}).Where("unit_id = ?", unit.ID).
+			Update("unit", unit.Name).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return unit, nil
+}
+
+// DeleteUnit 删除没有用户、仓房和点检记录的单位
+func DeleteUnit(id int) error {
+	unit, err := GetUnitByID(id)
+	if err != nil {
+		return err
+	}
+
+	var records, users, warehouses int64
+	// 回收站中的记录同样计入，彻底删除后才允许删除单位
+	if err := database.DB.Unscoped().Model(&models.InspectionRecord{}).Where("unit_id = ?", id).Count(&records).Error; err != nil {
+		return err
+	}
+	if err := database.DB.Table("user_units").Where("unit_id = ?", id).Count(&users).Error; err != nil {
+		return err
+	}
+	if err := database.DB.Model(&models.Warehouse{}).Where("unit_id = ?", id).Count(&warehouses).Error; err != nil {
+		return err
+	}
+	if records > 0 || users > 0 || warehouses > 0 {
+		return ErrUnitInUse
+	}
+	return database.DB.Delete(unit).Error
+}
+
+// GetUserUnitIDs 获取用户所属单位的ID列表
+func GetUserUnitIDs(userID int) ([]int, error) {
+	ids := []int{}
+	if err := database.DB.Table("user_units").Where("user_id = ?", userID).
+		Pluck("unit_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// SetUserUnits 设置用户所属的单位
+func SetUserUnits(userID int, unitIDs []int) (*models.User, error) {
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var units []models.Unit
+	if len(unitIDs) > 0 {
+		if err := database.DB.Where("id IN ?", unitIDs).Find(&units).Error; err != nil {
+			return nil, err
+		}
+		if len(units) != len(unitIDs) {
+			return nil, ErrUnitNotFound
+		}
+	}
+
+	if err := database.DB.Model(user).Association("Units").Replace(units); err != nil {
+		return nil, err
+	}
+	user.Units = units
+	return user, nil
+}
+
+// ResolveRecordUnit 确定点检记录所属的单位
+// 优先使用 unitID，否则按单位名称匹配；scope 不为 nil 时单位必须在其中
+func ResolveRecordUnit(unitID *int, unitName string, scope []int) (*models.Unit, error) {
+	var unit models.Unit
+	query := database.DB
+	if unitID != nil {
+		query = query.Where("id = ?", *unitID)
+	} else {
+		query = query.Where("name = ?", unitName)
+	}
+	if err := query.First(&unit).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUnitNotFound
+		}
+		return nil, err
+	}
+
+	if scope != nil && !containsID(scope, unit.ID) {
+		return nil, ErrUnitNotAccessible
+	}
+	return &unit, nil
+}
+
+// containsID 判断ID是否在列表中
+func containsID(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
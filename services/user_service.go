@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+	"DLM_backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// ErrUserNotFound 用户不存在
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrUserExists 同角色下用户名已存在
+var ErrUserExists = errors.New("username already exists for this role")
+
+// ErrUserHasRecords 用户存在点检记录，不能直接删除
+var ErrUserHasRecords = errors.New("user has inspection records, disable the account instead")
+
+// GetUsersWithFilters 分页查询用户列表，支持按姓名/手机号/用户名搜索和按角色过滤
+func GetUsersWithFilters(page, pageSize int, keyword, role string) (int64, []models.User, error) {
+	var users []models.User
+	var total int64
+	query := database.DB.Model(&models.User{})
+
+	if keyword != "" {
+		query = query.Where("username LIKE ? OR name LIKE ? OR phone LIKE ?",
+			"%"+keyword+"%", "%"+keyword+"%", "%"+keyword+"%")
+	}
+	if role != "" {
+		query = query.Where("role = ?", role)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return 0, nil, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Units").Order("id").Offset(offset).Limit(pageSize).Find(&users).Error; err != nil {
+		return 0, nil, err
+	}
+
+	return total, users, nil
+}
+
+// GetUserByID 根据ID获取用户
+func GetUserByID(id int) (*models.User, error) {
+	var user models.User
+	if err := database.DB.Preload("Units").First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateUser 新建用户，密码以哈希形式保存
+func CreateUser(user *models.User, password string) (*models.User, error) {
+	var count int64
+	if err := database.DB.Model(&models.User{}).
+		Where("username = ? AND role = ?", user.Username, user.Role).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrUserExists
+	}
+
+	if err := utils.ValidatePasswordStrength(password); err != nil {
+		return nil, err
+	}
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = hashed
+
+	if err := database.DB.Create(user).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UpdateUser 更新用户的基本信息和角色
+func UpdateUser(user *models.User) (*models.User, error) {
+	var count int64
+	if err := database.DB.Model(&models.User{}).
+		Where("username = ? AND role = ? AND id <> ?", user.Username, user.Role, user.ID).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrUserExists
+	}
+
+	if err := database.DB.Model(user).
+		Select("username", "role", "name", "phone", "updated_by").
+		Updates(user).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SetUserDisabled 停用或启用用户账号
+func SetUserDisabled(id int, disabled bool, actorID int) (*models.User, error) {
+	user, err := GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+	user.SetUpdater(actorID)
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"disabled":   disabled,
+		"updated_by": user.UpdatedBy,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ResetUserPassword 管理员重置用户密码，重置后该用户已登录的设备需要重新登录
+func ResetUserPassword(id int, password string, actorID int) error {
+	user, err := GetUserByID(id)
+	if err != nil {
+		return err
+	}
+	if err := utils.ValidatePasswordStrength(password); err != nil {
+		return err
+	}
+	return setPassword(user, password, actorID)
+}
+
+// DeleteUser 删除用户，已有点检记录的用户不允许删除
+func DeleteUser(id int) error {
+	if _, err := GetUserByID(id); err != nil {
+		return err
+	}
+
+	var count int64
+	// 回收站中的记录同样计入，彻底删除后才允许删除用户
+	if err := database.DB.Unscoped().Model(&models.InspectionRecord{}).Where("user_id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrUserHasRecords
+	}
+
+	return database.DB.Delete(&models.User{}, id).Error
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"errors"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrWarehouseNotFound 仓房不存在
+var ErrWarehouseNotFound = errors.New("warehouse not found")
+
+// ErrWarehouseExists 同一单位下仓号已存在
+var ErrWarehouseExists = errors.New("warehouse number already exists in this unit")
+
+// ErrWarehouseInUse 仓房下仍有挡粮门，不能删除
+var ErrWarehouseInUse = errors.New("warehouse still has grain doors")
+
+// ErrWarehouseUnitLocked 仓房已有挡粮门或点检计划，不能改到其他单位
+// 挡粮门下的点检记录、整改工单和点检计划按原单位划分访问范围，仓房改到其他单位会使数据跨单位可见
+var ErrWarehouseUnitLocked = errors.New("warehouse with grain doors or plans cannot be moved to another unit")
+
+// ErrGrainDoorNotFound 挡粮门不存在
+var ErrGrainDoorNotFound = errors.New("grain door not found")
+
+// ErrGrainDoorExists 同一仓房下门位置已存在
+var ErrGrainDoorExists = errors.New("grain door position already exists in this warehouse")
+
+// ErrGrainDoorInUse 挡粮门已有点检记录，不能删除
+var ErrGrainDoorInUse = errors.New("grain door still has inspection records")
+
+// ErrInvalidMasterData 仓号或门位置规范化后为空
+var ErrInvalidMasterData = errors.New("warehouse number and door position must not be empty")
+
+// GetWarehouses 获取仓房及其挡粮门，scope 不为 nil 时只返回其中单位的仓房，unitID 大于 0 时只返回该单位的仓房
+func GetWarehouses(scope []int, unitID int) ([]models.Warehouse, error) {
+	var warehouses []models.Warehouse
+	query := database.DB.Preload("Unit").Preload("Caretaker").
+		Preload("Doors", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Order("unit_id, number")
+	if scope != nil {
+		query = query.Where("unit_id IN ?", scope)
+	}
+	if unitID > 0 {
+		query = query.Where("unit_id = ?", unitID)
+	}
+	if err := query.Find(&warehouses).Error; err != nil {
+		return nil, err
+	}
+	return warehouses, nil
+}
+
+// GetWarehouseByID 根据ID获取仓房及其挡粮门
+func GetWarehouseByID(id int) (*models.Warehouse, error) {
+	var warehouse models.Warehouse
+	if err := database.DB.Preload("Unit").Preload("Caretaker").Preload("Doors").
+		First(&warehouse, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWarehouseNotFound
+		}
+		return nil, err
+	}
+	return &warehouse, nil
+}
+
+// checkWarehouse 规范化仓号，并校验单位存在且仓号在单位内未被占用
+func checkWarehouse(warehouse *models.Warehouse) error {
+	warehouse.Number = models.NormalizeWarehouseNumber(warehouse.Number)
+	if warehouse.Number == "" {
+		return ErrInvalidMasterData
+	}
+	if _, err := GetUnitByID(warehouse.UnitID); err != nil {
+		return err
+	}
+	if warehouse.CaretakerID != nil {
+		if _, err := GetUserByID(*warehouse.CaretakerID); err != nil {
+			return err
+		}
+	}
+
+	var count int64
+	if err := database.DB.Model(&models.Warehouse{}).
+		Where("unit_id = ? AND number = ? AND id <> ?", warehouse.UnitID, warehouse.Number, warehouse.ID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrWarehouseExists
+	}
+	return nil
+}
+
+// CreateWarehouse 新建仓房
+func CreateWarehouse(warehouse *models.Warehouse) (*models.Warehouse, error) {
+	if err := checkWarehouse(warehouse); err != nil {
+		return nil, err
+	}
+	if warehouse.Name == "" {
+		warehouse.Name = models.DefaultWarehouseName(warehouse.Number)
+	}
+	if err := database.DB.Create(warehouse).Error; err != nil {
+		return nil, err
+	}
+	return GetWarehouseByID(warehouse.ID)
+}
+
+// UpdateWarehouse 更新仓房信息，已有挡粮门或点检计划的仓房不能改到其他单位
+func UpdateWarehouse(warehouse *models.Warehouse) (*models.Warehouse, error) {
+	if err := checkWarehouse(warehouse); err != nil {
+		return nil, err
+	}
+	var previous models.Warehouse
+	if err := database.DB.Select("id", "unit_id").First(&previous, warehouse.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWarehouseNotFound
+		}
+		return nil, err
+	}
+	if previous.UnitID != warehouse.UnitID {
+		var doors int64
+		if err := database.DB.Model(&models.GrainDoor{}).Where("warehouse_id = ?", warehouse.ID).Count(&doors).Error; err != nil {
+			return nil, err
+		}
+		plans, err := countPlansReferencing("warehouse_id", warehouse.ID)
+		if err != nil {
+			return nil, err
+		}
+		if doors > 0 || plans > 0 {
+			return nil, ErrWarehouseUnitLocked
+		}
+	}
+	if err := database.DB.Omit("Unit", "Caretaker", "Doors", "created_at", "created_by").
+		Save(warehouse).Error; err != nil {
+		return nil, err
+	}
+	return GetWarehouseByID(warehouse.ID)
+}
+
+// DeleteWarehouse 删除没有挡粮门的仓房
+func DeleteWarehouse(id int) error {
+	if _, err := GetWarehouseByID(id); err != nil {
+		return err
+	}
+	var count int64
+	if err := database.DB.Model(&models.GrainDoor{}).Where("warehouse_id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrWarehouseInUse
+	}
+	if count, err := countPlansReferencing("warehouse_id", id); err != nil {
+		return err
+	} else if count > 0 {
+		return ErrPlanReferenced
+	}
+	return database.DB.Delete(&models.Warehouse{}, id).Error
+}
+
+// GetGrainDoorByID 根据ID获取挡粮门及其所属仓房和单位
+func GetGrainDoorByID(id int) (*models.GrainDoor, error) {
+	var door models.GrainDoor
+	if err := database.DB.Preload("Warehouse.Unit").First(&door, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGrainDoorNotFound
+		}
+		return nil, err
+	}
+	return &door, nil
+}
+
+// checkGrainDoor 规范化门位置，并校验仓房存在且门位置在仓房内未被占用
+func checkGrainDoor(door *models.GrainDoor) error {
+	door.Position = models.NormalizeDoorPosition(door.Position)
+	if door.Position == "" {
+		return ErrInvalidMasterData
+	}
+	if _, err := GetWarehouseByID(door.WarehouseID); err != nil {
+		return err
+	}
+
+	var count int64
+	if err := database.DB.Model(&models.GrainDoor{}).
+		Where("warehouse_id = ? AND position = ? AND id <> ?", door.WarehouseID, door.Position, door.ID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrGrainDoorExists
+	}
+	return nil
+}
+
+// CreateGrainDoor 新建挡粮门
+func CreateGrainDoor(door *models.GrainDoor) (*models.GrainDoor, error) {
+	if err := checkGrainDoor(door); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Create(door).Error; err != nil {
+		return nil, err
+	}
+	return GetGrainDoorByID(door.ID)
+}
+
+// UpdateGrainDoor 更新挡粮门信息
+func UpdateGrainDoor(door *models.GrainDoor) (*models.GrainDoor, error) {
+	if err := checkGrainDoor(door); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Omit("Warehouse", "created_at", "created_by").Save(door).Error; err != nil {
+		return nil, err
+	}
+	return GetGrainDoorByID(door.ID)
+}
+
+// DeleteGrainDoor 删除没有点检记录的挡粮门
+func DeleteGrainDoor(id int) error {
+	if _, err := GetGrainDoorByID(id); err != nil {
+		return err
+	}
+	// 回收站中的记录同样计入，彻底删除后才允许删除挡粮门
+	var count int64
+	if err := database.DB.Unscoped().Model(&models.InspectionRecord{}).
+		Where("grain_door_id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrGrainDoorInUse
+	}
+	if count, err := countPlansReferencing("grain_door_id", id); err != nil {
+		return err
+	} else if count > 0 {
+		return ErrPlanReferenced
+	}
+	return database.DB.Delete(&models.GrainDoor{}, id).Error
+}
+
+// ResolveRecordDoor 查找点检记录引用的挡粮门，挡粮门所属单位必须在用户可访问的范围内
+func ResolveRecordDoor(doorID int, scope []int) (*models.GrainDoor, error) {
+	door, err := GetGrainDoorByID(doorID)
+	if err != nil {
+		return nil, err
+	}
+	if scope != nil && !containsID(scope, door.Warehouse.UnitID) {
+		return nil, ErrUnitNotAccessible
+	}
+	return door, nil
+}
+
+// FindRecordDoor 按规范化后的仓号和门位置查找单位内的挡粮门，主数据中不存在时返回 nil
+func FindRecordDoor(unitID int, warehouseNumber, position string) (*models.GrainDoor, error) {
+	number := models.NormalizeWarehouseNumber(warehouseNumber)
+	position = models.NormalizeDoorPosition(position)
+	if number == "" || position == "" {
+		return nil, nil
+	}
+	var door models.GrainDoor
+	err := database.DB.Joins("JOIN warehouses ON warehouses.id = grain_doors.warehouse_id").
+		Where("warehouses.unit_id = ? AND warehouses.number = ? AND grain_doors.position = ?", unitID, number, position).
+		First(&door).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &door, nil
+}
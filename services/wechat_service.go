@@ -0,0 +1,167 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrWechatNotConfigured 未配置小程序 AppID/Secret
+var ErrWechatNotConfigured = errors.New("wechat login is not configured")
+
+// ErrWechatNotBound 该微信尚未绑定任何账号
+var ErrWechatNotBound = errors.New("wechat account is not bound, please log in with password and bind first")
+
+// ErrWechatAlreadyBound 该微信已绑定其他账号
+var ErrWechatAlreadyBound = errors.New("wechat account is already bound to another user")
+
+// wechatConfig 小程序登录配置，通过 SetWechatConfig 设置
+var wechatConfig struct {
+	AppID           string
+	AppSecret       string
+	Code2SessionURL string
+}
+
+// wechatClient 调用微信接口使用的 HTTP 客户端
+var wechatClient = &http.Client{Timeout: 5 * time.Second}
+
+// SetWechatConfig 设置小程序登录配置
+func SetWechatConfig(appID, appSecret, code2SessionURL string) {
+	wechatConfig.AppID = appID
+	wechatConfig.AppSecret = appSecret
+	wechatConfig.Code2SessionURL = code2SessionURL
+}
+
+// WechatSession code2session 接口的返回结果
+type WechatSession struct {
+	OpenID     string `json:"openid"`
+	SessionKey string `json:"session_key"`
+	UnionID    string `json:"unionid"`
+	ErrCode    int    `json:"errcode"`
+	ErrMsg     string `json:"errmsg"`
+}
+
+// Code2Session 使用 wx.login 获取的 code 换取 openid
+func Code2Session(code string) (*WechatSession, error) {
+	if wechatConfig.AppID == "" || wechatConfig.AppSecret == "" {
+		return nil, ErrWechatNotConfigured
+	}
+
+	query := url.Values{}
+	query.Set("appid", wechatConfig.AppID)
+	query.Set("secret", wechatConfig.AppSecret)
+	query.Set("js_code", code)
+	query.Set("grant_type", "authorization_code")
+
+	resp, err := wechatClient.Get(wechatConfig.Code2SessionURL + "?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("code2session request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var session WechatSession
+	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+		return nil, fmt.Errorf("code2session response invalid: %w", err)
+	}
+	if session.ErrCode != 0 {
+		return nil, fmt.Errorf("code2session failed: %d %s", session.ErrCode, session.ErrMsg)
+	}
+	if session.OpenID == "" {
+		return nil, errors.New("code2session returned empty openid")
+	}
+	return &session, nil
+}
+
+// WechatLogin 使用 wx.login 的 code 登录已绑定的账号
+// 微信登录没有用户名，失败次数按IP统计
+func WechatLogin(code string, client ClientInfo) (*TokenPair, *models.User, error) {
+	attempt := models.LoginAttempt{
+		Method:    "wechat",
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+
+	if err := CheckLoginAllowed("", client.IP); err != nil {
+		attempt.Reason = "locked"
+		WriteLoginAudit(&attempt)
+		return nil, nil, err
+	}
+
+	tokens, user, err := authenticateWechat(code, client)
+	if user != nil {
+		attempt.Username = user.Username
+		attempt.Role = user.Role
+	}
+	switch {
+	case err == nil:
+		attempt.Success = true
+		RecordLoginSuccess("", client.IP)
+	case errors.Is(err, ErrWechatNotBound):
+		attempt.Reason = err.Error()
+		RecordLoginFailure("", client.IP)
+	default:
+		attempt.Reason = err.Error()
+	}
+	WriteLoginAudit(&attempt)
+	return tokens, user, err
+}
+
+// authenticateWechat 换取 openid 并查找已绑定的账号
+func authenticateWechat(code string, client ClientInfo) (*TokenPair, *models.User, error) {
+	session, err := Code2Session(code)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var user models.User
+	if err := database.DB.Where("open_id = ?", session.OpenID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrWechatNotBound
+		}
+		return nil, nil, err
+	}
+	if user.Disabled {
+		return nil, &user, ErrAccountDisabled
+	}
+
+	tokens, err := IssueTokens(&user, client)
+	if err != nil {
+		return nil, &user, err
+	}
+	return tokens, &user, nil
+}
+
+// BindWechat 将 wx.login 的 code 对应的微信绑定到当前用户
+func BindWechat(user *models.User, code string) error {
+	session, err := Code2Session(code)
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	if err := database.DB.Model(&models.User{}).
+		Where("open_id = ? AND id <> ?", session.OpenID, user.ID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrWechatAlreadyBound
+	}
+
+	user.OpenID = &session.OpenID
+	return database.DB.Model(user).Update("open_id", session.OpenID).Error
+}
+
+// UnbindWechat 解除当前用户的微信绑定
+func UnbindWechat(user *models.User) error {
+	user.OpenID = nil
+	return database.DB.Model(user).Update("open_id", nil).Error
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -15,17 +17,47 @@ func SetJWTSecret(secret string) {
 	jwtSecret = []byte(secret)
 }
 
-// GenerateToken 根据用户名和角色生成 JWT 令牌
-func GenerateToken(username, role string) (string, error) {
+// accessTokenTTL 访问令牌有效期，可以通过 SetAccessTokenTTL 覆盖
+var accessTokenTTL = 30 * time.Minute
+
+// SetAccessTokenTTL 设置访问令牌有效期
+func SetAccessTokenTTL(ttl time.Duration) {
+	accessTokenTTL = ttl
+}
+
+// AccessTokenTTL 返回访问令牌有效期
+func AccessTokenTTL() time.Duration {
+	return accessTokenTTL
+}
+
+// GenerateToken 根据用户信息生成短期有效的 JWT 访问令牌
+// 令牌中携带用户的令牌版本号，版本号变更后旧令牌全部失效；jti 用于退出登录时单独吊销
+func GenerateToken(userID int, username, role string, tokenVersion int) (string, error) {
+	jti, err := RandomToken(16)
+	if err != nil {
+		return "", err
+	}
 	claims := jwt.MapClaims{
+		"jti":      jti,
+		"uid":      userID,
 		"username": username,
 		"role":     role,
-		"exp":      time.Now().Add(72 * time.Hour).Unix(),
+		"ver":      tokenVersion,
+		"exp":      time.Now().Add(accessTokenTTL).Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(jwtSecret)
 }
 
+// RandomToken 生成指定字节数的随机令牌，以十六进制字符串返回
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 // ParseToken 解析 JWT 令牌
 func ParseToken(tokenString string) (jwt.Claims, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
@@ -4,6 +4,9 @@ import (
 	"net/http"
 	"strings"
 
+	"DLM_backend/database"
+	"DLM_backend/models"
+
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 )
@@ -32,11 +35,50 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 将claims添加到上下文中，以便后续处理函数使用
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			c.Set("claims", claims)
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
 		}
 
+		// 校验令牌对应的用户仍然有效，且令牌版本未因修改密码等操作而失效
+		uid, _ := claims["uid"].(float64)
+		ver, _ := claims["ver"].(float64)
+		var user models.User
+		if err := database.DB.First(&user, int(uid)).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+		if user.Disabled {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "account disabled"})
+			return
+		}
+		if user.TokenVersion != int(ver) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+			return
+		}
+
+		// 校验访问令牌未因退出登录而被吊销
+		if jti, _ := claims["jti"].(string); jti != "" {
+			var revoked int64
+			if err := database.DB.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&revoked).Error; err != nil || revoked > 0 {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+				return
+			}
+		}
+
+		// 将claims和当前用户添加到上下文中，以便后续处理函数使用
+		c.Set("claims", claims)
+		c.Set("currentUser", &user)
+
 		c.Next()
 	}
 }
+
+// CurrentUser 获取 JWTAuthMiddleware 中加载的当前登录用户
+func CurrentUser(c *gin.Context) *models.User {
+	if user, exists := c.Get("currentUser"); exists {
+		return user.(*models.User)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordPolicy 密码强度策略
+type PasswordPolicy struct {
+	MinLength      int  // 最小长度
+	RequireLetter  bool // 必须包含字母
+	RequireDigit   bool // 必须包含数字
+	RequireSpecial bool // 必须包含特殊字符
+}
+
+// ErrWeakPassword 密码不满足强度策略
+var ErrWeakPassword = errors.New("weak password")
+
+// passwordPolicy 默认的密码强度策略，可以通过 SetPasswordPolicy 覆盖
+var passwordPolicy = PasswordPolicy{MinLength: 8, RequireLetter: true, RequireDigit: true}
+
+// SetPasswordPolicy 设置密码强度策略
+func SetPasswordPolicy(policy PasswordPolicy) {
+	passwordPolicy = policy
+}
+
+// ValidatePasswordStrength 按当前策略校验密码强度
+func ValidatePasswordStrength(password string) error {
+	if len([]rune(password)) < passwordPolicy.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrWeakPassword, passwordPolicy.MinLength)
+	}
+
+	var hasLetter, hasDigit, hasSpecial bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSpecial = true
+		}
+	}
+	if passwordPolicy.RequireLetter && !hasLetter {
+		return fmt.Errorf("%w: must contain at least one letter", ErrWeakPassword)
+	}
+	if passwordPolicy.RequireDigit && !hasDigit {
+		return fmt.Errorf("%w: must contain at least one digit", ErrWeakPassword)
+	}
+	if passwordPolicy.RequireSpecial && !hasSpecial {
+		return fmt.Errorf("%w: must contain at least one special character", ErrWeakPassword)
+	}
+	return nil
+}
+
+// HashPassword 使用 bcrypt 对明文密码进行哈希
+func HashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// IsPasswordHashed 判断存储的密码是否已经是 bcrypt 哈希
+func IsPasswordHashed(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") ||
+		strings.HasPrefix(stored, "$2b$") ||
+		strings.HasPrefix(stored, "$2y$")
+}
+
+// CheckPassword 校验明文密码与存储的密码是否匹配
+// 返回值 needsRehash 表示存储的是历史遗留的明文密码，校验通过后应重新哈希保存
+func CheckPassword(stored, password string) (ok bool, needsRehash bool) {
+	if IsPasswordHashed(stored) {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil, false
+	}
+
+	// 兼容旧数据：数据库中仍为明文密码，使用常量时间比较避免时序攻击
+	if stored == "" {
+		return false, false
+	}
+	ok = subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+	return ok, ok
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"net/http"
+
+	"DLM_backend/database"
+	"DLM_backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loadPermissions 加载当前用户角色拥有的权限，并缓存在请求上下文中
+func loadPermissions(c *gin.Context) map[string]bool {
+	if cached, exists := c.Get("permissions"); exists {
+		return cached.(map[string]bool)
+	}
+
+	permissions := make(map[string]bool)
+	if user := CurrentUser(c); user != nil {
+		var codes []string
+		database.DB.Model(&models.Permission{}).
+			Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+			Joins("JOIN roles ON roles.id = role_permissions.role_id").
+			Where("roles.name = ?", user.Role).
+			Pluck("permissions.code", &codes)
+		for _, code := range codes {
+			permissions[code] = true
+		}
+	}
+	c.Set("permissions", permissions)
+	return permissions
+}
+
+// HasPermission 判断当前登录用户是否拥有指定权限
+func HasPermission(c *gin.Context, code string) bool {
+	return loadPermissions(c)[code]
+}
+
+// RequirePermission 要求当前用户拥有任意一个指定权限，需在 JWTAuthMiddleware 之后使用
+func RequirePermission(codes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		permissions := loadPermissions(c)
+		for _, code := range codes {
+			if permissions[code] {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"success": false, "error": "permission denied"})
+	}
+}
@@ -16,6 +16,16 @@ func ErrorResponse(c *gin.Context, message string) {
 	c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": message})
 }
 
+// FieldErrorResponse 返回指明具体字段的参数错误响应
+func FieldErrorResponse(c *gin.Context, field, message string) {
+	c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": field + ": " + message, "field": field})
+}
+
+// CodedErrorResponse 返回带错误码的错误响应，客户端可按 code 区分失败原因
+func CodedErrorResponse(c *gin.Context, status int, code, message string) {
+	c.JSON(status, gin.H{"success": false, "error": message, "code": code})
+}
+
 // UnauthorizedResponse 返回未授权的错误响应
 func UnauthorizedResponse(c *gin.Context, message string) {
 	c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": message})
@@ -30,3 +40,18 @@ func ServerErrorResponse(c *gin.Context, message string) {
 func NotFoundResponse(c *gin.Context, message string) {
 	c.JSON(http.StatusNotFound, gin.H{"success": false, "error": message})
 }
+
+// ForbiddenResponse 返回无权限的错误响应
+func ForbiddenResponse(c *gin.Context, message string) {
+	c.JSON(http.StatusForbidden, gin.H{"success": false, "error": message})
+}
+
+// ConflictResponse 返回数据冲突的错误响应
+func ConflictResponse(c *gin.Context, message string) {
+	c.JSON(http.StatusConflict, gin.H{"success": false, "error": message})
+}
+
+// TooManyRequestsResponse 返回请求过于频繁的错误响应
+func TooManyRequestsResponse(c *gin.Context, message string) {
+	c.JSON(http.StatusTooManyRequests, gin.H{"success": false, "error": message})
+}